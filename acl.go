@@ -0,0 +1,289 @@
+package catalog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Access levels of the ACL rules, AccessWrite includes AccessRead
+const (
+	AccessDeny  = "deny"
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// CodePermissionDenied is the Code of the responses refused by the ACLs
+const CodePermissionDenied = 403
+
+// Rule grants the access to the services with a name starting with the Prefix,
+// empty Prefix means all of the services
+type Rule struct {
+	Prefix string `json:"prefix"`
+	Access string `json:"access"`
+}
+
+// Policy of a token, the longest matching prefix of the rules decides,
+// the services without matching rule are denied. Admin grants everything
+// including the ACL and the fault injection commands.
+type Policy struct {
+	Admin bool   `json:"admin"`
+	Rules []Rule `json:"rules"`
+}
+
+// Validate checks the access levels of the rules
+func (p *Policy) Validate() error {
+	for _, rule := range p.Rules {
+		switch rule.Access {
+		case AccessDeny, AccessRead, AccessWrite:
+		default:
+			return ErrInvalidPolicy
+		}
+	}
+	return nil
+}
+
+// allows reports whether the policy grants the access to the service
+func (p *Policy) allows(access string, service string) bool {
+	if p.Admin {
+		return true
+	}
+
+	var match *Rule
+	for i, rule := range p.Rules {
+		if strings.HasPrefix(service, rule.Prefix) && (match == nil || len(rule.Prefix) > len(match.Prefix)) {
+			match = &p.Rules[i]
+		}
+	}
+	if match == nil {
+		return false
+	}
+	switch access {
+	case AccessRead:
+		return match.Access == AccessRead || match.Access == AccessWrite
+	case AccessWrite:
+		return match.Access == AccessWrite
+	}
+	return false
+}
+
+// Token is the secret passed in the Token of the Request, like X-Consul-Token
+type Token struct {
+	SecretID    string `json:"secret_id"`
+	Description string `json:"description"`
+	Policy      Policy `json:"policy"`
+}
+
+// acl holds the tokens, nil means the ACLs are disabled and everything is allowed
+type acl struct {
+	mutex  sync.RWMutex
+	tokens map[string]Token
+}
+
+// newACL enables the ACLs with the admin master token
+func newACL(masterToken string) *acl {
+	return &acl{
+		tokens: map[string]Token{
+			masterToken: {SecretID: masterToken, Description: "master token", Policy: Policy{Admin: true}},
+		},
+	}
+}
+
+// policy returns the policy of the secret, nil for unknown secrets
+func (a *acl) policy(secretID string) *Policy {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	token, ok := a.tokens[secretID]
+	if !ok {
+		return nil
+	}
+	return &token.Policy
+}
+
+// set creates or replaces the token, a new secret is generated for empty SecretID
+func (a *acl) set(token Token) (Token, error) {
+	err := token.Policy.Validate()
+	if err != nil {
+		return Token{}, err
+	}
+	if token.SecretID == "" {
+		token.SecretID, err = newSecretID()
+		if err != nil {
+			return Token{}, err
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.tokens[token.SecretID] = token
+	return token, nil
+}
+
+func (a *acl) delete(secretID string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, ok := a.tokens[secretID]; !ok {
+		return ErrUndefinedToken
+	}
+	delete(a.tokens, secretID)
+	return nil
+}
+
+// list returns the tokens ordered by SecretID
+func (a *acl) list() []Token {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	var tokens = make([]Token, 0, len(a.tokens))
+	for _, token := range a.tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].SecretID < tokens[j].SecretID })
+	return tokens
+}
+
+func newSecretID() (string, error) {
+	var secret [16]byte
+	_, err := rand.Read(secret[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret[:]), nil
+}
+
+// aclTarget is the service of a request, the service-scoped requests share the id and name fields
+type aclTarget struct {
+	ID   *Identifier `json:"id"`
+	Name *string     `json:"name"`
+}
+
+// authorize checks the token of the request against the access needed by its command,
+// the policy is kept in the request for the commands filtering the services
+func (s *server) authorize(req *Request) error {
+	if s.acl == nil || req.Cmd == Hello {
+		return nil
+	}
+	policy := s.acl.policy(req.Token)
+	if policy == nil {
+		return ErrPermissionDenied
+	}
+	req.policy = policy
+
+	switch req.Cmd {
+	case Services, Dependencies:
+		// filtered by the policy
+		return nil
+	case Faults, ACLSetToken, ACLDeleteToken, ACLTokens:
+		if !policy.Admin {
+			return ErrPermissionDenied
+		}
+		return nil
+	}
+
+	access := AccessWrite
+	if req.Cmd == Service || req.Cmd == HealthHistory {
+		access = AccessRead
+	}
+	var target aclTarget
+	err := req.decodePayload(&target)
+	if err != nil {
+		return err
+	}
+	if !policy.allows(access, s.targetName(req.Cmd, target)) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// targetName resolves the name of the service of the request, the unknown
+// services are checked as the empty name so they can't be probed
+func (s *server) targetName(cmd Command, target aclTarget) string {
+	if cmd == Register {
+		if target.Name == nil {
+			return ""
+		}
+		return *target.Name
+	}
+	if target.ID == nil && target.Name == nil {
+		return ""
+	}
+	ss, err := s.storage.Service(target.ID, target.Name)
+	if err != nil {
+		return ""
+	}
+	return ss.Name
+}
+
+// readable reports whether the policy of the request grants reading the service
+func (r *Request) readable(service string) bool {
+	return r.policy == nil || r.policy.allows(AccessRead, service)
+}
+
+// deny answers the request with the code without running its command,
+// the payload has the success and error fields of every response
+func (r *Request) deny(code int, reason error) ([]byte, error) {
+	payload, err := r.encodePayload(ErrorResponse{Error: reason.Error()})
+	if err != nil {
+		return nil, err
+	}
+	resp := r.response(payload)
+	resp.Code = code
+	return r.encodeResponse(resp)
+}
+
+// setToken creates or replaces the token, the errors are reported in the response only
+func (s *server) setToken(req *ACLSetTokenRequest, resp *ACLSetTokenResponse) error {
+	resp.Meta = *req
+	if s.acl == nil {
+		resp.Error = ErrACLDisabled.Error()
+		resp.Success = false
+		return nil
+	}
+	token, err := s.acl.set(req.Token)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.Token = token
+	resp.Success = true
+	return nil
+}
+
+// deleteToken removes the token, the errors are reported in the response only
+func (s *server) deleteToken(req *ACLDeleteTokenRequest, resp *ACLDeleteTokenResponse) error {
+	resp.Meta = *req
+	if s.acl == nil {
+		resp.Error = ErrACLDisabled.Error()
+		resp.Success = false
+		return nil
+	}
+	err := s.acl.delete(req.SecretID)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.Success = true
+	return nil
+}
+
+// tokens lists the tokens, the errors are reported in the response only
+func (s *server) tokens(req *ACLTokensRequest, resp *ACLTokensResponse) error {
+	resp.Meta = *req
+	if s.acl == nil {
+		resp.Error = ErrACLDisabled.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.Tokens = s.acl.list()
+	resp.Success = true
+	return nil
+}
@@ -0,0 +1,159 @@
+package catalog
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPolicyAllows(t *testing.T) {
+	var policy = Policy{Rules: []Rule{
+		{Prefix: "", Access: AccessRead},
+		{Prefix: "web", Access: AccessWrite},
+		{Prefix: "web-admin", Access: AccessDeny},
+	}}
+	var cases = []struct {
+		access  string
+		service string
+		allowed bool
+	}{
+		{AccessRead, "auth", true},
+		{AccessWrite, "auth", false},
+		{AccessWrite, "webserver", true},
+		{AccessRead, "web-admin", false},
+		{AccessWrite, "web-admin-2", false},
+	}
+	for _, c := range cases {
+		if policy.allows(c.access, c.service) != c.allowed {
+			t.Errorf("%s access to %s should be %v", c.access, c.service, c.allowed)
+		}
+	}
+
+	if (&Policy{}).allows(AccessRead, "auth") {
+		t.Error("Policy without rules should deny")
+	}
+	if !(&Policy{Admin: true}).allows(AccessWrite, "auth") {
+		t.Error("Admin policy should allow everything")
+	}
+	if err := (&Policy{Rules: []Rule{{Access: "all"}}}).Validate(); err != ErrInvalidPolicy {
+		t.Errorf("Error should be %v, instead of %v", ErrInvalidPolicy, err)
+	}
+}
+
+func TestACL(t *testing.T) {
+	s := newTestServer(t, WithACL("master"))
+	authID, err := s.storage.Register("auth", localhost, 9016, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the admin creates the token of the web team
+	var setTokenResp ACLSetTokenResponse
+	aclRequest(t, s, "master", ACLSetToken, ACLSetTokenRequest{Token: Token{Description: "web team", Policy: Policy{Rules: []Rule{
+		{Prefix: "web", Access: AccessWrite},
+	}}}}, &setTokenResp)
+	web := setTokenResp.Token.SecretID
+	if !setTokenResp.Success || web == "" {
+		t.Fatalf("Token should be created with a secret, instead of %+v", setTokenResp)
+	}
+
+	var registerResp RegisterResponse
+	code := aclRequest(t, s, web, Register, RegisterRequest{Name: "webserver", Address: localhost, Port: 9017}, &registerResp)
+	if code != 0 || !registerResp.Success {
+		t.Fatalf("Register of webserver should be allowed, instead of %d %s", code, registerResp.Error)
+	}
+
+	// the write of the other services and the admin commands are denied
+	var denied = []struct {
+		token   string
+		cmd     Command
+		payload interface{}
+	}{
+		{web, Register, RegisterRequest{Name: "auth", Address: localhost, Port: 9018}},
+		{web, Deregister, DeregisterRequest{ID: &authID}},
+		{web, Service, ServiceRequest{ID: &authID}},
+		{web, Faults, FaultsRequest{}},
+		{web, ACLTokens, ACLTokensRequest{}},
+		{"unknown", Services, ServicesRequest{}},
+		{"", Service, ServiceRequest{ID: &registerResp.ID}},
+	}
+	for _, d := range denied {
+		var errorResp ErrorResponse
+		code := aclRequest(t, s, d.token, d.cmd, d.payload, &errorResp)
+		if code != CodePermissionDenied || errorResp.Success || errorResp.Error != ErrPermissionDenied.Error() {
+			t.Errorf("Command %d with token %q should be denied, instead of %d %+v", d.cmd, d.token, code, errorResp)
+		}
+	}
+	if _, err := s.storage.Service(&authID, nil); err != nil {
+		t.Errorf("auth shouldn't be deregistered, instead of %v", err)
+	}
+
+	// the services are filtered by the policy
+	var servicesResp ServicesResponse
+	aclRequest(t, s, web, Services, ServicesRequest{}, &servicesResp)
+	if servicesResp.Total != 1 || servicesResp.Services[0].Name != "webserver" {
+		t.Errorf("Only webserver should be visible, instead of %+v", servicesResp.Services)
+	}
+	aclRequest(t, s, "master", Services, ServicesRequest{}, &servicesResp)
+	if servicesResp.Total != 2 {
+		t.Errorf("Admin should see 2 services, instead of %d", servicesResp.Total)
+	}
+
+	// the deleted token is denied
+	var deleteTokenResp ACLDeleteTokenResponse
+	aclRequest(t, s, "master", ACLDeleteToken, ACLDeleteTokenRequest{SecretID: web}, &deleteTokenResp)
+	if !deleteTokenResp.Success {
+		t.Fatalf("Token should be deleted, instead of %s", deleteTokenResp.Error)
+	}
+	var errorResp ErrorResponse
+	if code := aclRequest(t, s, web, Services, ServicesRequest{}, &errorResp); code != CodePermissionDenied {
+		t.Errorf("Deleted token should be denied, instead of %d", code)
+	}
+}
+
+func TestACLStaleResponse(t *testing.T) {
+	s := newTestServer(t, WithACL("master"))
+	for _, name := range []string{"web", "secret"} {
+		if _, err := s.storage.Register(name, localhost, 9019, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.acl.set(Token{SecretID: "web", Policy: Policy{Rules: []Rule{{Prefix: "web", Access: AccessRead}}}}); err != nil {
+		t.Fatal(err)
+	}
+	var faultsResp FaultsResponse
+	aclRequest(t, s, "master", Faults, FaultsRequest{Faults: []Fault{{Commands: []Command{Services}, StaleRate: 1}}}, &faultsResp)
+	if !faultsResp.Success {
+		t.Fatalf("Faults should be set, instead of %s", faultsResp.Error)
+	}
+
+	// the stale response of the admin isn't served to the restricted token
+	var servicesResp ServicesResponse
+	aclRequest(t, s, "master", Services, ServicesRequest{}, &servicesResp)
+	if servicesResp.Total != 2 {
+		t.Fatalf("Admin should see 2 services, instead of %d", servicesResp.Total)
+	}
+	aclRequest(t, s, "web", Services, ServicesRequest{}, &servicesResp)
+	if servicesResp.Total != 1 || servicesResp.Services[0].Name != "web" {
+		t.Errorf("Only web should be visible, instead of %+v", servicesResp.Services)
+	}
+}
+
+// aclRequest sends the v1 request with the token and returns the code of the response
+func aclRequest(t *testing.T, s *server, token string, cmd Command, payload interface{}, v interface{}) int {
+	t.Helper()
+	payloadJSON, _ := json.Marshal(payload)
+	rJSON, _ := json.Marshal(Request{Cmd: cmd, Req: string(payloadJSON), Token: token})
+	message, err := s.handleRequest(rJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(message, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(resp.Resp), v); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Code
+}
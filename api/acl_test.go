@@ -0,0 +1,44 @@
+package api
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/PumpkinSeed/catalog"
+)
+
+func TestToken(t *testing.T) {
+	var server = catalog.NewServer("127.0.0.1:0", nil, &sync.RWMutex{}, catalog.WithACL("master"))
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	addr := server.Addr().String()
+
+	var admin = NewCatalog(addr, WithToken("master"))
+	defer admin.Close()
+	token, err := admin.SetToken(catalog.Token{Policy: catalog.Policy{Rules: []catalog.Rule{
+		{Prefix: "web", Access: catalog.AccessWrite},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var web = NewCatalog(addr, WithToken(token.SecretID))
+	defer web.Close()
+	if _, err := web.Register("webserver", "localhost", 8080, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := web.Register("auth", "localhost", 8001, nil, nil); err != catalog.ErrPermissionDenied {
+		t.Errorf("Error should be %v, instead of %v", catalog.ErrPermissionDenied, err)
+	}
+	if _, err := web.Tokens(); err != catalog.ErrPermissionDenied {
+		t.Errorf("Error should be %v, instead of %v", catalog.ErrPermissionDenied, err)
+	}
+
+	var anonymous = NewCatalog(addr)
+	defer anonymous.Close()
+	if _, err := anonymous.Services(); err != catalog.ErrPermissionDenied {
+		t.Errorf("Error should be %v, instead of %v", catalog.ErrPermissionDenied, err)
+	}
+}
@@ -2,11 +2,14 @@ package api
 
 import (
 	"bufio"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/PumpkinSeed/catalog"
@@ -15,46 +18,117 @@ import (
 const delimiter = "\n"
 const delimiterByte = '\n'
 
+// unixScheme is the prefix of the unix domain socket addresses, e.g. unix:///run/catalog.sock
+const unixScheme = "unix://"
+
 type Catalog interface {
 	Register(name string, host string, port int, tags []string, additional interface{}) (string, error)
+	RegisterWithCheck(name string, host string, port int, tags []string, additional interface{}, check *catalog.CheckDefinition) (string, error)
+	RegisterWithChecks(name string, host string, port int, tags []string, additional interface{}, policy catalog.HealthPolicy, checks []catalog.CheckDefinition) (string, error)
+	RegisterService(rr catalog.RegisterRequest) (string, error)
+	AddCheck(id *string, name *string, check catalog.CheckDefinition) error
+	RemoveCheck(id *string, name *string, check string) error
+	HealthHistory(id *string, name *string, check string, limit int) ([]catalog.HealthRecord, error)
+	SetHealth(id string, status catalog.Status, reason string, duration time.Duration) error
+	ClearOverride(id string) error
+	SetFaults(faults []catalog.Fault) error
+	DependencyGraph() (catalog.DependencyGraph, error)
+	EnableMaintenance(id *string, name *string, reason string) error
+	DisableMaintenance(id *string, name *string) error
 	Deregister(id *string, name *string) error
 	Service(id *string, name *string) (*catalog.ServiceSpec, error)
 	Services() ([]catalog.ServiceSpec, error)
+	HealthyServices() ([]catalog.ServiceSpec, error)
+	SetToken(token catalog.Token) (catalog.Token, error)
+	DeleteToken(secretID string) error
+	Tokens() ([]catalog.Token, error)
+	ServicesPage(sortBy string, limit int, offset int) ([]catalog.ServiceSpec, int, error)
+	Close() error
 }
 
 type catalogapi struct {
 	addr string
+	// codec preferred for the connection
+	codec string
+	// tlsConfig of the connections, nil means plain TCP
+	tlsConfig *tls.Config
+	// token sent with every request
+	token string
+
+	// version of the protocol negotiated with the server, conn is shared by
+	// the concurrent calls from ProtocolV2 and dialed again after it broke
+	mutex   sync.Mutex
+	version int
+	conn    *conn
 }
 
-func NewCatalog(addr string) Catalog {
-	return &catalogapi{addr: addr}
+// Option configures the Catalog
+type Option func(c *catalogapi)
+
+// WithCodec sets the codec of the connection, the server falls back to
+// catalog.CodecJSON if it doesn't support the codec
+func WithCodec(name string) Option {
+	return func(c *catalogapi) {
+		c.codec = name
+	}
 }
 
-func (c *catalogapi) Register(name string, host string, port int, tags []string, additional interface{}) (string, error) {
-	var rr = catalog.RegisterRequest{
-		Name:    name,
-		Address: host,
-		Port:    port,
-		Tags:    tags,
+// WithTLS connects to the server over TLS, the config with Certificates
+// authenticates the client to a server requiring mutual TLS
+func WithTLS(config *tls.Config) Option {
+	return func(c *catalogapi) {
+		c.tlsConfig = config
 	}
+}
 
-	rrJSON, err := json.Marshal(rr)
-	if err != nil {
-		return "", err
+// WithToken sends the SecretID of the ACL token with every request,
+// like the X-Consul-Token header of Consul
+func WithToken(secretID string) Option {
+	return func(c *catalogapi) {
+		c.token = secretID
 	}
+}
 
-	var mainRequest = catalog.Request{
-		Cmd: catalog.Register,
-		Req: string(rrJSON),
+// NewCatalog returns the client of the server on addr, host:port or unix://path
+func NewCatalog(addr string, options ...Option) Catalog {
+	c := &catalogapi{addr: addr, codec: catalog.CodecJSON}
+	for _, option := range options {
+		option(c)
 	}
+	return c
+}
 
-	resp, err := c.do(mainRequest)
-	if err != nil {
-		return "", err
+func (c *catalogapi) Register(name string, host string, port int, tags []string, additional interface{}) (string, error) {
+	return c.RegisterWithCheck(name, host, port, tags, additional, nil)
+}
+
+// RegisterWithCheck registers the service with a healthcheck run by the server
+func (c *catalogapi) RegisterWithCheck(name string, host string, port int, tags []string, additional interface{}, check *catalog.CheckDefinition) (string, error) {
+	var checks []catalog.CheckDefinition
+	if check != nil {
+		checks = append(checks, *check)
 	}
+	return c.RegisterWithChecks(name, host, port, tags, additional, catalog.HealthPolicy{}, checks)
+}
+
+// RegisterWithChecks registers the service with named healthchecks run by the server,
+// the status of the service computed from them with the policy
+func (c *catalogapi) RegisterWithChecks(name string, host string, port int, tags []string, additional interface{}, policy catalog.HealthPolicy, checks []catalog.CheckDefinition) (string, error) {
+	return c.RegisterService(catalog.RegisterRequest{
+		Name:         name,
+		Address:      host,
+		Port:         port,
+		Tags:         tags,
+		Additional:   additional,
+		Checks:       checks,
+		HealthPolicy: policy,
+	})
+}
 
+// RegisterService registers the service with every option of the register request
+func (c *catalogapi) RegisterService(rr catalog.RegisterRequest) (string, error) {
 	var respRegister catalog.RegisterResponse
-	err = json.Unmarshal([]byte(resp.Resp), &respRegister)
+	err := c.call(catalog.Register, rr, &respRegister)
 	if err != nil {
 		return "", err
 	}
@@ -83,33 +157,174 @@ func (c *catalogapi) Deregister(id *string, name *string) error {
 		}
 	}
 
-	drJSON, err := json.Marshal(dr)
+	var respDeregister catalog.DeregisterResponse
+	err = c.call(catalog.Deregister, dr, &respDeregister)
+	if err != nil {
+		return err
+	}
+
+	if respDeregister.Success {
+		return nil
+	}
+
+	return errors.New(respDeregister.Error)
+}
+
+// AddCheck adds the named check to the service identified by id or name
+func (c *catalogapi) AddCheck(id *string, name *string, check catalog.CheckDefinition) error {
+	catalogID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	var respAddCheck catalog.AddCheckResponse
+	err = c.call(catalog.AddCheck, catalog.AddCheckRequest{ID: catalogID, Name: name, Check: check}, &respAddCheck)
 	if err != nil {
 		return err
 	}
 
-	var mainRequest = catalog.Request{
-		Cmd: catalog.Deregister,
-		Req: string(drJSON),
+	if respAddCheck.Success {
+		return nil
 	}
+	return errors.New(respAddCheck.Error)
+}
 
-	resp, err := c.do(mainRequest)
+// RemoveCheck removes the named check of the service identified by id or name
+func (c *catalogapi) RemoveCheck(id *string, name *string, check string) error {
+	catalogID, err := parseID(id)
 	if err != nil {
 		return err
 	}
 
-	var respDeregister catalog.DeregisterResponse
-	err = json.Unmarshal([]byte(resp.Resp), &respDeregister)
+	var respRemoveCheck catalog.RemoveCheckResponse
+	err = c.call(catalog.RemoveCheck, catalog.RemoveCheckRequest{ID: catalogID, Name: name, Check: check}, &respRemoveCheck)
 	if err != nil {
 		return err
 	}
 
-	if respDeregister.Success {
+	if respRemoveCheck.Success {
 		return nil
 	}
+	return errors.New(respRemoveCheck.Error)
+}
 
-	return errors.New(respDeregister.Error)
+// HealthHistory returns the latest check results of the service from the oldest
+// to the latest, empty check means all of the checks and limit 0 means all of the results
+func (c *catalogapi) HealthHistory(id *string, name *string, check string, limit int) ([]catalog.HealthRecord, error) {
+	catalogID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var respHistory catalog.HealthHistoryResponse
+	err = c.call(catalog.HealthHistory, catalog.HealthHistoryRequest{ID: catalogID, Name: name, Check: check, Limit: limit}, &respHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	if respHistory.Success {
+		return respHistory.Records, nil
+	}
+	return nil, errors.New(respHistory.Error)
+}
+
+// SetHealth overrides the status of the service for the duration regardless of its
+// checks, zero duration means until ClearOverride
+func (c *catalogapi) SetHealth(id string, status catalog.Status, reason string, duration time.Duration) error {
+	catalogID, err := parseID(&id)
+	if err != nil {
+		return err
+	}
+
+	var respSetHealth catalog.SetHealthResponse
+	err = c.call(catalog.SetHealth, catalog.SetHealthRequest{ID: *catalogID, Status: status, Reason: reason, Duration: duration}, &respSetHealth)
+	if err != nil {
+		return err
+	}
+
+	if respSetHealth.Success {
+		return nil
+	}
+	return errors.New(respSetHealth.Error)
+}
+
+// ClearOverride removes the health override of the service
+func (c *catalogapi) ClearOverride(id string) error {
+	catalogID, err := parseID(&id)
+	if err != nil {
+		return err
+	}
+
+	var respClearOverride catalog.ClearOverrideResponse
+	err = c.call(catalog.ClearOverride, catalog.ClearOverrideRequest{ID: *catalogID}, &respClearOverride)
+	if err != nil {
+		return err
+	}
+
+	if respClearOverride.Success {
+		return nil
+	}
+	return errors.New(respClearOverride.Error)
+}
+
+// SetFaults replaces the faults injected by the server, nil switches the injection off
+func (c *catalogapi) SetFaults(faults []catalog.Fault) error {
+	var respFaults catalog.FaultsResponse
+	err := c.call(catalog.Faults, catalog.FaultsRequest{Faults: faults}, &respFaults)
+	if err != nil {
+		return err
+	}
+
+	if respFaults.Success {
+		return nil
+	}
+	return errors.New(respFaults.Error)
+}
+
+// DependencyGraph returns the names of the dependencies of the services by name
+func (c *catalogapi) DependencyGraph() (catalog.DependencyGraph, error) {
+	var respDependencies catalog.DependenciesResponse
+	err := c.call(catalog.Dependencies, catalog.DependenciesRequest{}, &respDependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	if respDependencies.Success {
+		return respDependencies.Graph, nil
+	}
+	return nil, errors.New(respDependencies.Error)
 }
+
+// EnableMaintenance takes the service identified by id, or every instance of the service
+// with the name, out of the healthy services
+func (c *catalogapi) EnableMaintenance(id *string, name *string, reason string) error {
+	return c.maintenance(catalog.EnableMaintenance, id, name, reason)
+}
+
+// DisableMaintenance takes the service identified by id, or every instance of the service
+// with the name, out of maintenance
+func (c *catalogapi) DisableMaintenance(id *string, name *string) error {
+	return c.maintenance(catalog.DisableMaintenance, id, name, "")
+}
+
+func (c *catalogapi) maintenance(cmd catalog.Command, id *string, name *string, reason string) error {
+	catalogID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	var respMaintenance catalog.MaintenanceResponse
+	err = c.call(cmd, catalog.MaintenanceRequest{ID: catalogID, Name: name, Reason: reason}, &respMaintenance)
+	if err != nil {
+		return err
+	}
+
+	if respMaintenance.Success {
+		return nil
+	}
+	return errors.New(respMaintenance.Error)
+}
+
 func (c *catalogapi) Service(id *string, name *string) (*catalog.ServiceSpec, error) {
 	var idUint uint64
 	var err error
@@ -128,77 +343,204 @@ func (c *catalogapi) Service(id *string, name *string) (*catalog.ServiceSpec, er
 		}
 	}
 
-	srJSON, err := json.Marshal(sr)
+	var respService catalog.ServiceResponse
+	err = c.call(catalog.Service, sr, &respService)
 	if err != nil {
 		return nil, err
 	}
 
-	var mainRequest = catalog.Request{
-		Cmd: catalog.Service,
-		Req: string(srJSON),
+	if respService.Success {
+		return &respService.Service, nil
+	}
+
+	return nil, errors.New(respService.Error)
+}
+
+// SetToken creates or replaces the ACL token, empty SecretID generates a new one.
+// It needs an admin token.
+func (c *catalogapi) SetToken(token catalog.Token) (catalog.Token, error) {
+	var respSetToken catalog.ACLSetTokenResponse
+	err := c.call(catalog.ACLSetToken, catalog.ACLSetTokenRequest{Token: token}, &respSetToken)
+	if err != nil {
+		return catalog.Token{}, err
 	}
 
-	resp, err := c.do(mainRequest)
+	if respSetToken.Success {
+		return respSetToken.Token, nil
+	}
+	return catalog.Token{}, errors.New(respSetToken.Error)
+}
+
+// DeleteToken removes the ACL token, it needs an admin token
+func (c *catalogapi) DeleteToken(secretID string) error {
+	var respDeleteToken catalog.ACLDeleteTokenResponse
+	err := c.call(catalog.ACLDeleteToken, catalog.ACLDeleteTokenRequest{SecretID: secretID}, &respDeleteToken)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var respService catalog.ServiceResponse
-	err = json.Unmarshal([]byte(resp.Resp), &respService)
+	if respDeleteToken.Success {
+		return nil
+	}
+	return errors.New(respDeleteToken.Error)
+}
+
+// Tokens returns the ACL tokens, it needs an admin token
+func (c *catalogapi) Tokens() ([]catalog.Token, error) {
+	var respTokens catalog.ACLTokensResponse
+	err := c.call(catalog.ACLTokens, catalog.ACLTokensRequest{}, &respTokens)
 	if err != nil {
 		return nil, err
 	}
 
-	if respService.Success {
-		return &respService.Service, nil
+	if respTokens.Success {
+		return respTokens.Tokens, nil
 	}
-
-	return nil, errors.New(respService.Error)
+	return nil, errors.New(respTokens.Error)
 }
+
 func (c *catalogapi) Services() ([]catalog.ServiceSpec, error) {
-	var sr = catalog.ServicesRequest{}
+	services, _, err := c.ServicesPage("", 0, 0)
+	return services, err
+}
+
+// HealthyServices returns the alive services and the ones without healthcheck,
+// the services in maintenance are left out
+func (c *catalogapi) HealthyServices() ([]catalog.ServiceSpec, error) {
+	services, _, err := c.services(catalog.ServicesRequest{Healthy: true})
+	return services, err
+}
 
-	srJSON, err := json.Marshal(sr)
+// ServicesPage returns the services ordered by sortBy and the total number of services,
+// limit 0 means all of the services
+func (c *catalogapi) ServicesPage(sortBy string, limit int, offset int) ([]catalog.ServiceSpec, int, error) {
+	return c.services(catalog.ServicesRequest{
+		SortBy: sortBy,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+func (c *catalogapi) services(sr catalog.ServicesRequest) ([]catalog.ServiceSpec, int, error) {
+	var respServices catalog.ServicesResponse
+	err := c.call(catalog.Services, sr, &respServices)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	var mainRequest = catalog.Request{
-		Cmd: catalog.Services,
-		Req: string(srJSON),
+	if respServices.Success {
+		return respServices.Services, respServices.Total, nil
 	}
 
-	resp, err := c.do(mainRequest)
+	return nil, 0, errors.New(respServices.Error)
+}
+
+// parseID parses the optional string ID of a service
+func parseID(id *string) (*catalog.Identifier, error) {
+	if id == nil {
+		return nil, nil
+	}
+	idUint, err := strconv.ParseUint(*id, 10, 64)
 	if err != nil {
 		return nil, err
 	}
+	catalogID := catalog.Identifier(idUint)
+	return &catalogID, nil
+}
 
-	var respServices catalog.ServicesResponse
-	err = json.Unmarshal([]byte(resp.Resp), &respServices)
+// call sends the payload as the cmd in the negotiated version of the protocol
+// and decodes the payload of the response into out
+func (c *catalogapi) call(cmd catalog.Command, payload interface{}, out interface{}) error {
+	conn, err := c.connection()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if conn != nil {
+		return conn.call(catalog.Request{Cmd: cmd, Token: c.token}, payload, out)
 	}
 
-	if respServices.Success {
-		return respServices.Services, nil
+	// the servers of v1 answer a single JSON request per connection
+	pJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
 	}
+	resp, err := c.roundTrip(catalog.Request{Cmd: cmd, Req: string(pJSON), Token: c.token})
+	if err != nil {
+		return err
+	}
+	if resp.Code == catalog.CodePermissionDenied {
+		return catalog.ErrPermissionDenied
+	}
+	return json.Unmarshal([]byte(resp.Resp), out)
+}
+
+// connection returns the shared connection to the server, nil if the server speaks
+// only ProtocolV1. The version and the codec are negotiated when it is dialed.
+func (c *catalogapi) connection() (*conn, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	return nil, errors.New(respServices.Error)
+	if c.version == catalog.ProtocolV1 {
+		return nil, nil
+	}
+	if c.conn == nil || c.conn.broken() {
+		netConn, err := c.dial()
+		if err != nil {
+			return nil, err
+		}
+		conn, version, err := open(netConn, c.codec)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+		c.version = version
+	}
+	return c.conn, nil
 }
 
-func (c *catalogapi) do(req catalog.Request) (*catalog.Response, error) {
+// Close closes the shared connection to the server
+func (c *catalogapi) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.close()
+	c.conn = nil
+	return err
+}
+
+// dial connects to the server over TLS if it is configured,
+// the addresses with unix:// prefix are unix domain sockets
+func (c *catalogapi) dial() (net.Conn, error) {
+	network, address := "tcp", c.addr
+	if strings.HasPrefix(c.addr, unixScheme) {
+		network, address = "unix", strings.TrimPrefix(c.addr, unixScheme)
+	}
+
+	if c.tlsConfig != nil {
+		return tls.Dial(network, address, c.tlsConfig)
+	}
+	return net.Dial(network, address)
+}
+
+func (c *catalogapi) roundTrip(req catalog.Request) (*catalog.Response, error) {
 	rJSON, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
-	conn, err := net.Dial("tcp", c.addr)
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 
-	fmt.Fprintf(conn, string(rJSON)+"\n")
+	_, err = conn.Write(append(rJSON, delimiterByte))
+	if err != nil {
+		return nil, err
+	}
 
 	message, _ := bufio.NewReader(conn).ReadBytes(delimiterByte)
 	var resp catalog.Response
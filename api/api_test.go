@@ -13,7 +13,7 @@ import (
 
 var (
 	testCatalogInstance Catalog
-	binAddr             = "127.0.0.1:7777"
+	binAddr             string
 	testServices        = []*struct {
 		id         string
 		name       string
@@ -53,10 +53,12 @@ var (
 )
 
 func init() {
-	var server = catalog.NewServer(binAddr, hcStorage, &sync.RWMutex{})
-	go func() {
-		panic(server.Listen())
-	}()
+	var server = catalog.NewServer("127.0.0.1:0", hcStorage, &sync.RWMutex{})
+	err := server.Start()
+	if err != nil {
+		panic(err)
+	}
+	binAddr = server.Addr().String()
 	startServices()
 }
 
@@ -131,6 +133,56 @@ func TestServicesAfterDeregister(t *testing.T) {
 	}
 }
 
+func TestConcurrentCalls(t *testing.T) {
+	var wg sync.WaitGroup
+	var errs = make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// the failing lookups are answered on the shared connection too
+			if i%5 == 0 {
+				var missing = "missing"
+				_, err := testCatalogInstance.Service(nil, &missing)
+				if err == nil || err.Error() != catalog.ErrUndefinedService.Error() {
+					err = fmt.Errorf("error should be %v, instead of %v", catalog.ErrUndefinedService, err)
+				} else {
+					err = nil
+				}
+				errs <- err
+				return
+			}
+			services, err := testCatalogInstance.Services()
+			if err == nil && len(services) != 2 {
+				err = fmt.Errorf("there should be 2 services, instead of %d", len(services))
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestBinaryCodec(t *testing.T) {
+	for _, codec := range []string{catalog.CodecMsgpack, catalog.CodecCBOR} {
+		var instance = NewCatalog(binAddr, WithCodec(codec))
+		services, err := instance.Services()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(services) != 2 {
+			t.Errorf("%s: there should be 2 services, instead of %d", codec, len(services))
+		}
+		instance.Close()
+	}
+}
+
 func startServices() {
 	for _, service := range testServices {
 		go func(addr string, closeChan chan bool) {
@@ -0,0 +1,1425 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: catalog.proto
+
+// The gRPC API of the catalog, served from the same storage as the
+// newline JSON socket protocol. The errors are gRPC status codes:
+// NotFound for undefined services, InvalidArgument for invalid requests
+// and PermissionDenied for the tokens denied by the ACLs. The token is
+// sent in the x-catalog-token metadata.
+
+package catalogpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type WatchEvent_Kind int32
+
+const (
+	WatchEvent_KIND_UNSPECIFIED   WatchEvent_Kind = 0
+	WatchEvent_KIND_REGISTER      WatchEvent_Kind = 1
+	WatchEvent_KIND_DEREGISTER    WatchEvent_Kind = 2
+	WatchEvent_KIND_HEALTH_CHANGE WatchEvent_Kind = 3
+	WatchEvent_KIND_UPDATE        WatchEvent_Kind = 4
+)
+
+// Enum value maps for WatchEvent_Kind.
+var (
+	WatchEvent_Kind_name = map[int32]string{
+		0: "KIND_UNSPECIFIED",
+		1: "KIND_REGISTER",
+		2: "KIND_DEREGISTER",
+		3: "KIND_HEALTH_CHANGE",
+		4: "KIND_UPDATE",
+	}
+	WatchEvent_Kind_value = map[string]int32{
+		"KIND_UNSPECIFIED":   0,
+		"KIND_REGISTER":      1,
+		"KIND_DEREGISTER":    2,
+		"KIND_HEALTH_CHANGE": 3,
+		"KIND_UPDATE":        4,
+	}
+)
+
+func (x WatchEvent_Kind) Enum() *WatchEvent_Kind {
+	p := new(WatchEvent_Kind)
+	*p = x
+	return p
+}
+
+func (x WatchEvent_Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WatchEvent_Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_catalog_proto_enumTypes[0].Descriptor()
+}
+
+func (WatchEvent_Kind) Type() protoreflect.EnumType {
+	return &file_catalog_proto_enumTypes[0]
+}
+
+func (x WatchEvent_Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WatchEvent_Kind.Descriptor instead.
+func (WatchEvent_Kind) EnumDescriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{12, 0}
+}
+
+// CheckDefinition is a declarative healthcheck run by the server itself,
+// see the Go CheckDefinition for the defaults.
+type CheckDefinition struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// tcp, http, grpc or exec
+	Type          string               `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Weight        int32                `protobuf:"varint,3,opt,name=weight,proto3" json:"weight,omitempty"`
+	Address       string               `protobuf:"bytes,4,opt,name=address,proto3" json:"address,omitempty"`
+	Url           string               `protobuf:"bytes,5,opt,name=url,proto3" json:"url,omitempty"`
+	Status        int32                `protobuf:"varint,6,opt,name=status,proto3" json:"status,omitempty"`
+	Body          string               `protobuf:"bytes,7,opt,name=body,proto3" json:"body,omitempty"`
+	GrpcService   string               `protobuf:"bytes,8,opt,name=grpc_service,json=grpcService,proto3" json:"grpc_service,omitempty"`
+	Command       []string             `protobuf:"bytes,9,rep,name=command,proto3" json:"command,omitempty"`
+	ExitCode      int32                `protobuf:"varint,10,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	Interval      *durationpb.Duration `protobuf:"bytes,11,opt,name=interval,proto3" json:"interval,omitempty"`
+	Timeout       *durationpb.Duration `protobuf:"bytes,12,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckDefinition) Reset() {
+	*x = CheckDefinition{}
+	mi := &file_catalog_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckDefinition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckDefinition) ProtoMessage() {}
+
+func (x *CheckDefinition) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckDefinition.ProtoReflect.Descriptor instead.
+func (*CheckDefinition) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CheckDefinition) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CheckDefinition) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CheckDefinition) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *CheckDefinition) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *CheckDefinition) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *CheckDefinition) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *CheckDefinition) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *CheckDefinition) GetGrpcService() string {
+	if x != nil {
+		return x.GrpcService
+	}
+	return ""
+}
+
+func (x *CheckDefinition) GetCommand() []string {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+func (x *CheckDefinition) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *CheckDefinition) GetInterval() *durationpb.Duration {
+	if x != nil {
+		return x.Interval
+	}
+	return nil
+}
+
+func (x *CheckDefinition) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+type HealthPolicy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// all, any or weighted
+	Mode          string  `protobuf:"bytes,1,opt,name=mode,proto3" json:"mode,omitempty"`
+	Threshold     float64 `protobuf:"fixed64,2,opt,name=threshold,proto3" json:"threshold,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthPolicy) Reset() {
+	*x = HealthPolicy{}
+	mi := &file_catalog_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthPolicy) ProtoMessage() {}
+
+func (x *HealthPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthPolicy.ProtoReflect.Descriptor instead.
+func (*HealthPolicy) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *HealthPolicy) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *HealthPolicy) GetThreshold() float64 {
+	if x != nil {
+		return x.Threshold
+	}
+	return 0
+}
+
+type Dependency struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Required      bool                   `protobuf:"varint,2,opt,name=required,proto3" json:"required,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Dependency) Reset() {
+	*x = Dependency{}
+	mi := &file_catalog_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Dependency) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Dependency) ProtoMessage() {}
+
+func (x *Dependency) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Dependency.ProtoReflect.Descriptor instead.
+func (*Dependency) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Dependency) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Dependency) GetRequired() bool {
+	if x != nil {
+		return x.Required
+	}
+	return false
+}
+
+type RegisterRequest struct {
+	state                   protoimpl.MessageState `protogen:"open.v1"`
+	Name                    string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Address                 string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Port                    int32                  `protobuf:"varint,3,opt,name=port,proto3" json:"port,omitempty"`
+	Tags                    []string               `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	Additional              *structpb.Value        `protobuf:"bytes,5,opt,name=additional,proto3" json:"additional,omitempty"`
+	Checks                  []*CheckDefinition     `protobuf:"bytes,6,rep,name=checks,proto3" json:"checks,omitempty"`
+	HealthPolicy            *HealthPolicy          `protobuf:"bytes,7,opt,name=health_policy,json=healthPolicy,proto3" json:"health_policy,omitempty"`
+	DeregisterCriticalAfter *durationpb.Duration   `protobuf:"bytes,8,opt,name=deregister_critical_after,json=deregisterCriticalAfter,proto3" json:"deregister_critical_after,omitempty"`
+	Dependencies            []*Dependency          `protobuf:"bytes,9,rep,name=dependencies,proto3" json:"dependencies,omitempty"`
+	unknownFields           protoimpl.UnknownFields
+	sizeCache               protoimpl.SizeCache
+}
+
+func (x *RegisterRequest) Reset() {
+	*x = RegisterRequest{}
+	mi := &file_catalog_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterRequest) ProtoMessage() {}
+
+func (x *RegisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterRequest.ProtoReflect.Descriptor instead.
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RegisterRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *RegisterRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetAdditional() *structpb.Value {
+	if x != nil {
+		return x.Additional
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetChecks() []*CheckDefinition {
+	if x != nil {
+		return x.Checks
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetHealthPolicy() *HealthPolicy {
+	if x != nil {
+		return x.HealthPolicy
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetDeregisterCriticalAfter() *durationpb.Duration {
+	if x != nil {
+		return x.DeregisterCriticalAfter
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetDependencies() []*Dependency {
+	if x != nil {
+		return x.Dependencies
+	}
+	return nil
+}
+
+type RegisterResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// the checks which didn't pass on their first run
+	CheckFailures map[string]*CheckResult `protobuf:"bytes,2,rep,name=check_failures,json=checkFailures,proto3" json:"check_failures,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterResponse) Reset() {
+	*x = RegisterResponse{}
+	mi := &file_catalog_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterResponse) ProtoMessage() {}
+
+func (x *RegisterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterResponse.ProtoReflect.Descriptor instead.
+func (*RegisterResponse) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RegisterResponse) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *RegisterResponse) GetCheckFailures() map[string]*CheckResult {
+	if x != nil {
+		return x.CheckFailures
+	}
+	return nil
+}
+
+type DeregisterRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Service:
+	//
+	//	*DeregisterRequest_Id
+	//	*DeregisterRequest_Name
+	Service       isDeregisterRequest_Service `protobuf_oneof:"service"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeregisterRequest) Reset() {
+	*x = DeregisterRequest{}
+	mi := &file_catalog_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeregisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeregisterRequest) ProtoMessage() {}
+
+func (x *DeregisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeregisterRequest.ProtoReflect.Descriptor instead.
+func (*DeregisterRequest) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeregisterRequest) GetService() isDeregisterRequest_Service {
+	if x != nil {
+		return x.Service
+	}
+	return nil
+}
+
+func (x *DeregisterRequest) GetId() uint64 {
+	if x != nil {
+		if x, ok := x.Service.(*DeregisterRequest_Id); ok {
+			return x.Id
+		}
+	}
+	return 0
+}
+
+func (x *DeregisterRequest) GetName() string {
+	if x != nil {
+		if x, ok := x.Service.(*DeregisterRequest_Name); ok {
+			return x.Name
+		}
+	}
+	return ""
+}
+
+type isDeregisterRequest_Service interface {
+	isDeregisterRequest_Service()
+}
+
+type DeregisterRequest_Id struct {
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3,oneof"`
+}
+
+type DeregisterRequest_Name struct {
+	Name string `protobuf:"bytes,2,opt,name=name,proto3,oneof"`
+}
+
+func (*DeregisterRequest_Id) isDeregisterRequest_Service() {}
+
+func (*DeregisterRequest_Name) isDeregisterRequest_Service() {}
+
+type DeregisterResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeregisterResponse) Reset() {
+	*x = DeregisterResponse{}
+	mi := &file_catalog_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeregisterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeregisterResponse) ProtoMessage() {}
+
+func (x *DeregisterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeregisterResponse.ProtoReflect.Descriptor instead.
+func (*DeregisterResponse) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{6}
+}
+
+type ServiceRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Service:
+	//
+	//	*ServiceRequest_Id
+	//	*ServiceRequest_Name
+	Service       isServiceRequest_Service `protobuf_oneof:"service"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServiceRequest) Reset() {
+	*x = ServiceRequest{}
+	mi := &file_catalog_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceRequest) ProtoMessage() {}
+
+func (x *ServiceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceRequest.ProtoReflect.Descriptor instead.
+func (*ServiceRequest) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ServiceRequest) GetService() isServiceRequest_Service {
+	if x != nil {
+		return x.Service
+	}
+	return nil
+}
+
+func (x *ServiceRequest) GetId() uint64 {
+	if x != nil {
+		if x, ok := x.Service.(*ServiceRequest_Id); ok {
+			return x.Id
+		}
+	}
+	return 0
+}
+
+func (x *ServiceRequest) GetName() string {
+	if x != nil {
+		if x, ok := x.Service.(*ServiceRequest_Name); ok {
+			return x.Name
+		}
+	}
+	return ""
+}
+
+type isServiceRequest_Service interface {
+	isServiceRequest_Service()
+}
+
+type ServiceRequest_Id struct {
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3,oneof"`
+}
+
+type ServiceRequest_Name struct {
+	Name string `protobuf:"bytes,2,opt,name=name,proto3,oneof"`
+}
+
+func (*ServiceRequest_Id) isServiceRequest_Service() {}
+
+func (*ServiceRequest_Name) isServiceRequest_Service() {}
+
+type ServiceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Service       *ServiceSpec           `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServiceResponse) Reset() {
+	*x = ServiceResponse{}
+	mi := &file_catalog_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceResponse) ProtoMessage() {}
+
+func (x *ServiceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceResponse.ProtoReflect.Descriptor instead.
+func (*ServiceResponse) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ServiceResponse) GetService() *ServiceSpec {
+	if x != nil {
+		return x.Service
+	}
+	return nil
+}
+
+type ServicesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// name, id or registered_at, empty means name
+	SortBy string `protobuf:"bytes,1,opt,name=sort_by,json=sortBy,proto3" json:"sort_by,omitempty"`
+	// zero means no limit
+	Limit  int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset int32 `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	// only the alive services and the ones without healthcheck,
+	// the services in maintenance are left out
+	Healthy       bool `protobuf:"varint,4,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServicesRequest) Reset() {
+	*x = ServicesRequest{}
+	mi := &file_catalog_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServicesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServicesRequest) ProtoMessage() {}
+
+func (x *ServicesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServicesRequest.ProtoReflect.Descriptor instead.
+func (*ServicesRequest) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ServicesRequest) GetSortBy() string {
+	if x != nil {
+		return x.SortBy
+	}
+	return ""
+}
+
+func (x *ServicesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ServicesRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *ServicesRequest) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+type ServicesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         int32                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	Services      []*ServiceSpec         `protobuf:"bytes,2,rep,name=services,proto3" json:"services,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServicesResponse) Reset() {
+	*x = ServicesResponse{}
+	mi := &file_catalog_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServicesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServicesResponse) ProtoMessage() {}
+
+func (x *ServicesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServicesResponse.ProtoReflect.Descriptor instead.
+func (*ServicesResponse) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ServicesResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ServicesResponse) GetServices() []*ServiceSpec {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+type WatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// name of the watched services, empty means all of the services
+	Name          string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_catalog_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *WatchRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type WatchEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          WatchEvent_Kind        `protobuf:"varint,1,opt,name=kind,proto3,enum=catalog.v1.WatchEvent_Kind" json:"kind,omitempty"`
+	Service       *ServiceSpec           `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEvent) Reset() {
+	*x = WatchEvent{}
+	mi := &file_catalog_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEvent) ProtoMessage() {}
+
+func (x *WatchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEvent.ProtoReflect.Descriptor instead.
+func (*WatchEvent) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *WatchEvent) GetKind() WatchEvent_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return WatchEvent_KIND_UNSPECIFIED
+}
+
+func (x *WatchEvent) GetService() *ServiceSpec {
+	if x != nil {
+		return x.Service
+	}
+	return nil
+}
+
+type CheckResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// passing, warning or critical
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Output        string                 `protobuf:"bytes,2,opt,name=output,proto3" json:"output,omitempty"`
+	LastChecked   *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=last_checked,json=lastChecked,proto3" json:"last_checked,omitempty"`
+	LastChange    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_change,json=lastChange,proto3" json:"last_change,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckResult) Reset() {
+	*x = CheckResult{}
+	mi := &file_catalog_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckResult) ProtoMessage() {}
+
+func (x *CheckResult) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckResult.ProtoReflect.Descriptor instead.
+func (*CheckResult) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CheckResult) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *CheckResult) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *CheckResult) GetLastChecked() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastChecked
+	}
+	return nil
+}
+
+func (x *CheckResult) GetLastChange() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastChange
+	}
+	return nil
+}
+
+type Maintenance struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reason        string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	Since         *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Maintenance) Reset() {
+	*x = Maintenance{}
+	mi := &file_catalog_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Maintenance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Maintenance) ProtoMessage() {}
+
+func (x *Maintenance) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Maintenance.ProtoReflect.Descriptor instead.
+func (*Maintenance) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Maintenance) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Maintenance) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+type ServiceSpec struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Id           uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name         string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Host         string                 `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`
+	Port         int32                  `protobuf:"varint,4,opt,name=port,proto3" json:"port,omitempty"`
+	Address      string                 `protobuf:"bytes,5,opt,name=address,proto3" json:"address,omitempty"`
+	Tags         []string               `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	RegisteredAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=registered_at,json=registeredAt,proto3" json:"registered_at,omitempty"`
+	IsAlive      bool                   `protobuf:"varint,8,opt,name=is_alive,json=isAlive,proto3" json:"is_alive,omitempty"`
+	// empty when the service has no healthcheck
+	Status           string                  `protobuf:"bytes,9,opt,name=status,proto3" json:"status,omitempty"`
+	Checks           map[string]*CheckResult `protobuf:"bytes,10,rep,name=checks,proto3" json:"checks,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Maintenance      *Maintenance            `protobuf:"bytes,11,opt,name=maintenance,proto3" json:"maintenance,omitempty"`
+	DependencyStatus string                  `protobuf:"bytes,12,opt,name=dependency_status,json=dependencyStatus,proto3" json:"dependency_status,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *ServiceSpec) Reset() {
+	*x = ServiceSpec{}
+	mi := &file_catalog_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceSpec) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceSpec) ProtoMessage() {}
+
+func (x *ServiceSpec) ProtoReflect() protoreflect.Message {
+	mi := &file_catalog_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceSpec.ProtoReflect.Descriptor instead.
+func (*ServiceSpec) Descriptor() ([]byte, []int) {
+	return file_catalog_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ServiceSpec) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ServiceSpec) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ServiceSpec) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *ServiceSpec) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *ServiceSpec) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *ServiceSpec) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ServiceSpec) GetRegisteredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RegisteredAt
+	}
+	return nil
+}
+
+func (x *ServiceSpec) GetIsAlive() bool {
+	if x != nil {
+		return x.IsAlive
+	}
+	return false
+}
+
+func (x *ServiceSpec) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ServiceSpec) GetChecks() map[string]*CheckResult {
+	if x != nil {
+		return x.Checks
+	}
+	return nil
+}
+
+func (x *ServiceSpec) GetMaintenance() *Maintenance {
+	if x != nil {
+		return x.Maintenance
+	}
+	return nil
+}
+
+func (x *ServiceSpec) GetDependencyStatus() string {
+	if x != nil {
+		return x.DependencyStatus
+	}
+	return ""
+}
+
+var File_catalog_proto protoreflect.FileDescriptor
+
+const file_catalog_proto_rawDesc = "" +
+	"\n" +
+	"\rcatalog.proto\x12\n" +
+	"catalog.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xef\x02\n" +
+	"\x0fCheckDefinition\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x16\n" +
+	"\x06weight\x18\x03 \x01(\x05R\x06weight\x12\x18\n" +
+	"\aaddress\x18\x04 \x01(\tR\aaddress\x12\x10\n" +
+	"\x03url\x18\x05 \x01(\tR\x03url\x12\x16\n" +
+	"\x06status\x18\x06 \x01(\x05R\x06status\x12\x12\n" +
+	"\x04body\x18\a \x01(\tR\x04body\x12!\n" +
+	"\fgrpc_service\x18\b \x01(\tR\vgrpcService\x12\x18\n" +
+	"\acommand\x18\t \x03(\tR\acommand\x12\x1b\n" +
+	"\texit_code\x18\n" +
+	" \x01(\x05R\bexitCode\x125\n" +
+	"\binterval\x18\v \x01(\v2\x19.google.protobuf.DurationR\binterval\x123\n" +
+	"\atimeout\x18\f \x01(\v2\x19.google.protobuf.DurationR\atimeout\"@\n" +
+	"\fHealthPolicy\x12\x12\n" +
+	"\x04mode\x18\x01 \x01(\tR\x04mode\x12\x1c\n" +
+	"\tthreshold\x18\x02 \x01(\x01R\tthreshold\"<\n" +
+	"\n" +
+	"Dependency\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\brequired\x18\x02 \x01(\bR\brequired\"\xa6\x03\n" +
+	"\x0fRegisterRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\x12\x12\n" +
+	"\x04port\x18\x03 \x01(\x05R\x04port\x12\x12\n" +
+	"\x04tags\x18\x04 \x03(\tR\x04tags\x126\n" +
+	"\n" +
+	"additional\x18\x05 \x01(\v2\x16.google.protobuf.ValueR\n" +
+	"additional\x123\n" +
+	"\x06checks\x18\x06 \x03(\v2\x1b.catalog.v1.CheckDefinitionR\x06checks\x12=\n" +
+	"\rhealth_policy\x18\a \x01(\v2\x18.catalog.v1.HealthPolicyR\fhealthPolicy\x12U\n" +
+	"\x19deregister_critical_after\x18\b \x01(\v2\x19.google.protobuf.DurationR\x17deregisterCriticalAfter\x12:\n" +
+	"\fdependencies\x18\t \x03(\v2\x16.catalog.v1.DependencyR\fdependencies\"\xd5\x01\n" +
+	"\x10RegisterResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12V\n" +
+	"\x0echeck_failures\x18\x02 \x03(\v2/.catalog.v1.RegisterResponse.CheckFailuresEntryR\rcheckFailures\x1aY\n" +
+	"\x12CheckFailuresEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12-\n" +
+	"\x05value\x18\x02 \x01(\v2\x17.catalog.v1.CheckResultR\x05value:\x028\x01\"F\n" +
+	"\x11DeregisterRequest\x12\x10\n" +
+	"\x02id\x18\x01 \x01(\x04H\x00R\x02id\x12\x14\n" +
+	"\x04name\x18\x02 \x01(\tH\x00R\x04nameB\t\n" +
+	"\aservice\"\x14\n" +
+	"\x12DeregisterResponse\"C\n" +
+	"\x0eServiceRequest\x12\x10\n" +
+	"\x02id\x18\x01 \x01(\x04H\x00R\x02id\x12\x14\n" +
+	"\x04name\x18\x02 \x01(\tH\x00R\x04nameB\t\n" +
+	"\aservice\"D\n" +
+	"\x0fServiceResponse\x121\n" +
+	"\aservice\x18\x01 \x01(\v2\x17.catalog.v1.ServiceSpecR\aservice\"r\n" +
+	"\x0fServicesRequest\x12\x17\n" +
+	"\asort_by\x18\x01 \x01(\tR\x06sortBy\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x05R\x06offset\x12\x18\n" +
+	"\ahealthy\x18\x04 \x01(\bR\ahealthy\"]\n" +
+	"\x10ServicesResponse\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x05R\x05total\x123\n" +
+	"\bservices\x18\x02 \x03(\v2\x17.catalog.v1.ServiceSpecR\bservices\"\"\n" +
+	"\fWatchRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\xdf\x01\n" +
+	"\n" +
+	"WatchEvent\x12/\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\x1b.catalog.v1.WatchEvent.KindR\x04kind\x121\n" +
+	"\aservice\x18\x02 \x01(\v2\x17.catalog.v1.ServiceSpecR\aservice\"m\n" +
+	"\x04Kind\x12\x14\n" +
+	"\x10KIND_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rKIND_REGISTER\x10\x01\x12\x13\n" +
+	"\x0fKIND_DEREGISTER\x10\x02\x12\x16\n" +
+	"\x12KIND_HEALTH_CHANGE\x10\x03\x12\x0f\n" +
+	"\vKIND_UPDATE\x10\x04\"\xb9\x01\n" +
+	"\vCheckResult\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x16\n" +
+	"\x06output\x18\x02 \x01(\tR\x06output\x12=\n" +
+	"\flast_checked\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\vlastChecked\x12;\n" +
+	"\vlast_change\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"lastChange\"W\n" +
+	"\vMaintenance\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\x120\n" +
+	"\x05since\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\"\xf4\x03\n" +
+	"\vServiceSpec\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04host\x18\x03 \x01(\tR\x04host\x12\x12\n" +
+	"\x04port\x18\x04 \x01(\x05R\x04port\x12\x18\n" +
+	"\aaddress\x18\x05 \x01(\tR\aaddress\x12\x12\n" +
+	"\x04tags\x18\x06 \x03(\tR\x04tags\x12?\n" +
+	"\rregistered_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\fregisteredAt\x12\x19\n" +
+	"\bis_alive\x18\b \x01(\bR\aisAlive\x12\x16\n" +
+	"\x06status\x18\t \x01(\tR\x06status\x12;\n" +
+	"\x06checks\x18\n" +
+	" \x03(\v2#.catalog.v1.ServiceSpec.ChecksEntryR\x06checks\x129\n" +
+	"\vmaintenance\x18\v \x01(\v2\x17.catalog.v1.MaintenanceR\vmaintenance\x12+\n" +
+	"\x11dependency_status\x18\f \x01(\tR\x10dependencyStatus\x1aR\n" +
+	"\vChecksEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12-\n" +
+	"\x05value\x18\x02 \x01(\v2\x17.catalog.v1.CheckResultR\x05value:\x028\x012\xe5\x02\n" +
+	"\aCatalog\x12E\n" +
+	"\bRegister\x12\x1b.catalog.v1.RegisterRequest\x1a\x1c.catalog.v1.RegisterResponse\x12K\n" +
+	"\n" +
+	"Deregister\x12\x1d.catalog.v1.DeregisterRequest\x1a\x1e.catalog.v1.DeregisterResponse\x12B\n" +
+	"\aService\x12\x1a.catalog.v1.ServiceRequest\x1a\x1b.catalog.v1.ServiceResponse\x12E\n" +
+	"\bServices\x12\x1b.catalog.v1.ServicesRequest\x1a\x1c.catalog.v1.ServicesResponse\x12;\n" +
+	"\x05Watch\x12\x18.catalog.v1.WatchRequest\x1a\x16.catalog.v1.WatchEvent0\x01B.Z,github.com/PumpkinSeed/catalog/api/catalogpbb\x06proto3"
+
+var (
+	file_catalog_proto_rawDescOnce sync.Once
+	file_catalog_proto_rawDescData []byte
+)
+
+func file_catalog_proto_rawDescGZIP() []byte {
+	file_catalog_proto_rawDescOnce.Do(func() {
+		file_catalog_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_catalog_proto_rawDesc), len(file_catalog_proto_rawDesc)))
+	})
+	return file_catalog_proto_rawDescData
+}
+
+var file_catalog_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_catalog_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_catalog_proto_goTypes = []any{
+	(WatchEvent_Kind)(0),          // 0: catalog.v1.WatchEvent.Kind
+	(*CheckDefinition)(nil),       // 1: catalog.v1.CheckDefinition
+	(*HealthPolicy)(nil),          // 2: catalog.v1.HealthPolicy
+	(*Dependency)(nil),            // 3: catalog.v1.Dependency
+	(*RegisterRequest)(nil),       // 4: catalog.v1.RegisterRequest
+	(*RegisterResponse)(nil),      // 5: catalog.v1.RegisterResponse
+	(*DeregisterRequest)(nil),     // 6: catalog.v1.DeregisterRequest
+	(*DeregisterResponse)(nil),    // 7: catalog.v1.DeregisterResponse
+	(*ServiceRequest)(nil),        // 8: catalog.v1.ServiceRequest
+	(*ServiceResponse)(nil),       // 9: catalog.v1.ServiceResponse
+	(*ServicesRequest)(nil),       // 10: catalog.v1.ServicesRequest
+	(*ServicesResponse)(nil),      // 11: catalog.v1.ServicesResponse
+	(*WatchRequest)(nil),          // 12: catalog.v1.WatchRequest
+	(*WatchEvent)(nil),            // 13: catalog.v1.WatchEvent
+	(*CheckResult)(nil),           // 14: catalog.v1.CheckResult
+	(*Maintenance)(nil),           // 15: catalog.v1.Maintenance
+	(*ServiceSpec)(nil),           // 16: catalog.v1.ServiceSpec
+	nil,                           // 17: catalog.v1.RegisterResponse.CheckFailuresEntry
+	nil,                           // 18: catalog.v1.ServiceSpec.ChecksEntry
+	(*durationpb.Duration)(nil),   // 19: google.protobuf.Duration
+	(*structpb.Value)(nil),        // 20: google.protobuf.Value
+	(*timestamppb.Timestamp)(nil), // 21: google.protobuf.Timestamp
+}
+var file_catalog_proto_depIdxs = []int32{
+	19, // 0: catalog.v1.CheckDefinition.interval:type_name -> google.protobuf.Duration
+	19, // 1: catalog.v1.CheckDefinition.timeout:type_name -> google.protobuf.Duration
+	20, // 2: catalog.v1.RegisterRequest.additional:type_name -> google.protobuf.Value
+	1,  // 3: catalog.v1.RegisterRequest.checks:type_name -> catalog.v1.CheckDefinition
+	2,  // 4: catalog.v1.RegisterRequest.health_policy:type_name -> catalog.v1.HealthPolicy
+	19, // 5: catalog.v1.RegisterRequest.deregister_critical_after:type_name -> google.protobuf.Duration
+	3,  // 6: catalog.v1.RegisterRequest.dependencies:type_name -> catalog.v1.Dependency
+	17, // 7: catalog.v1.RegisterResponse.check_failures:type_name -> catalog.v1.RegisterResponse.CheckFailuresEntry
+	16, // 8: catalog.v1.ServiceResponse.service:type_name -> catalog.v1.ServiceSpec
+	16, // 9: catalog.v1.ServicesResponse.services:type_name -> catalog.v1.ServiceSpec
+	0,  // 10: catalog.v1.WatchEvent.kind:type_name -> catalog.v1.WatchEvent.Kind
+	16, // 11: catalog.v1.WatchEvent.service:type_name -> catalog.v1.ServiceSpec
+	21, // 12: catalog.v1.CheckResult.last_checked:type_name -> google.protobuf.Timestamp
+	21, // 13: catalog.v1.CheckResult.last_change:type_name -> google.protobuf.Timestamp
+	21, // 14: catalog.v1.Maintenance.since:type_name -> google.protobuf.Timestamp
+	21, // 15: catalog.v1.ServiceSpec.registered_at:type_name -> google.protobuf.Timestamp
+	18, // 16: catalog.v1.ServiceSpec.checks:type_name -> catalog.v1.ServiceSpec.ChecksEntry
+	15, // 17: catalog.v1.ServiceSpec.maintenance:type_name -> catalog.v1.Maintenance
+	14, // 18: catalog.v1.RegisterResponse.CheckFailuresEntry.value:type_name -> catalog.v1.CheckResult
+	14, // 19: catalog.v1.ServiceSpec.ChecksEntry.value:type_name -> catalog.v1.CheckResult
+	4,  // 20: catalog.v1.Catalog.Register:input_type -> catalog.v1.RegisterRequest
+	6,  // 21: catalog.v1.Catalog.Deregister:input_type -> catalog.v1.DeregisterRequest
+	8,  // 22: catalog.v1.Catalog.Service:input_type -> catalog.v1.ServiceRequest
+	10, // 23: catalog.v1.Catalog.Services:input_type -> catalog.v1.ServicesRequest
+	12, // 24: catalog.v1.Catalog.Watch:input_type -> catalog.v1.WatchRequest
+	5,  // 25: catalog.v1.Catalog.Register:output_type -> catalog.v1.RegisterResponse
+	7,  // 26: catalog.v1.Catalog.Deregister:output_type -> catalog.v1.DeregisterResponse
+	9,  // 27: catalog.v1.Catalog.Service:output_type -> catalog.v1.ServiceResponse
+	11, // 28: catalog.v1.Catalog.Services:output_type -> catalog.v1.ServicesResponse
+	13, // 29: catalog.v1.Catalog.Watch:output_type -> catalog.v1.WatchEvent
+	25, // [25:30] is the sub-list for method output_type
+	20, // [20:25] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
+}
+
+func init() { file_catalog_proto_init() }
+func file_catalog_proto_init() {
+	if File_catalog_proto != nil {
+		return
+	}
+	file_catalog_proto_msgTypes[5].OneofWrappers = []any{
+		(*DeregisterRequest_Id)(nil),
+		(*DeregisterRequest_Name)(nil),
+	}
+	file_catalog_proto_msgTypes[7].OneofWrappers = []any{
+		(*ServiceRequest_Id)(nil),
+		(*ServiceRequest_Name)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_catalog_proto_rawDesc), len(file_catalog_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   18,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_catalog_proto_goTypes,
+		DependencyIndexes: file_catalog_proto_depIdxs,
+		EnumInfos:         file_catalog_proto_enumTypes,
+		MessageInfos:      file_catalog_proto_msgTypes,
+	}.Build()
+	File_catalog_proto = out.File
+	file_catalog_proto_goTypes = nil
+	file_catalog_proto_depIdxs = nil
+}
@@ -0,0 +1,6 @@
+// Package catalogpb is the gRPC API of the catalog, generated from catalog.proto.
+// The server is enabled with catalog.WithGRPC, the client is NewCatalogClient
+// on a grpc.ClientConn, the ACL token goes in the x-catalog-token metadata.
+package catalogpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative catalog.proto
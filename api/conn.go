@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/PumpkinSeed/catalog"
+)
+
+// conn is a single connection to the server multiplexing the concurrent
+// requests by their ID, the responses might arrive out of order
+type conn struct {
+	conn       net.Conn
+	codec      catalog.Codec
+	version    int
+	writeMutex sync.Mutex
+
+	mutex   sync.Mutex
+	nextID  uint64
+	pending map[string]chan result
+	// err is the error which broke the connection
+	err error
+}
+
+type result struct {
+	resp *catalog.Response
+	err  error
+}
+
+var errUnknownCodec = errors.New("unknown codec")
+
+// open negotiates the version of the protocol and the codec on the connection,
+// the connection to a server of ProtocolV1 is closed and nil returned
+func open(netConn net.Conn, codec string) (*conn, int, error) {
+	hrJSON, err := json.Marshal(catalog.HelloRequest{
+		Versions: []int{catalog.ProtocolV2, catalog.ProtocolV1},
+		Codecs:   []string{codec},
+	})
+	if err != nil {
+		netConn.Close()
+		return nil, 0, err
+	}
+	rJSON, err := json.Marshal(catalog.Request{Cmd: catalog.Hello, Version: catalog.ProtocolV2, Payload: hrJSON})
+	if err != nil {
+		netConn.Close()
+		return nil, 0, err
+	}
+	_, err = netConn.Write(append(rJSON, delimiterByte))
+	if err != nil {
+		netConn.Close()
+		return nil, 0, err
+	}
+
+	// the handshake is always JSON, the negotiated codec is used from the next message
+	reader := bufio.NewReader(netConn)
+	message, _ := reader.ReadBytes(delimiterByte)
+	var resp catalog.Response
+	err = json.Unmarshal(message, &resp)
+	if err != nil {
+		netConn.Close()
+		return nil, 0, err
+	}
+
+	// the servers without the handshake answer with an empty v1 response
+	var respHello catalog.HelloResponse
+	if resp.Version < catalog.ProtocolV2 || json.Unmarshal(resp.Payload, &respHello) != nil || !respHello.Success {
+		netConn.Close()
+		return nil, catalog.ProtocolV1, nil
+	}
+	negotiated, ok := catalog.CodecByName(respHello.Codec)
+	if !ok {
+		netConn.Close()
+		return nil, 0, errUnknownCodec
+	}
+
+	c := &conn{
+		conn:    netConn,
+		codec:   negotiated,
+		version: respHello.Version,
+		pending: make(map[string]chan result),
+	}
+	go c.read(reader)
+	return c, respHello.Version, nil
+}
+
+// call sends the request with the payload and decodes the payload of the response into out
+func (c *conn) call(req catalog.Request, payload interface{}, out interface{}) error {
+	p, err := c.codec.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req.Version = c.version
+	req.Payload = p
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	if resp.Code == catalog.CodePermissionDenied {
+		return catalog.ErrPermissionDenied
+	}
+	return c.codec.Unmarshal(resp.Payload, out)
+}
+
+// do sends the request with a new ID and waits for its response
+func (c *conn) do(req catalog.Request) (*catalog.Response, error) {
+	var ch = make(chan result, 1)
+	c.mutex.Lock()
+	if c.err != nil {
+		c.mutex.Unlock()
+		return nil, c.err
+	}
+	c.nextID++
+	req.ID = strconv.FormatUint(c.nextID, 10)
+	c.pending[req.ID] = ch
+	c.mutex.Unlock()
+
+	msg, err := c.codec.Marshal(req)
+	if err != nil {
+		c.mutex.Lock()
+		delete(c.pending, req.ID)
+		c.mutex.Unlock()
+		return nil, err
+	}
+
+	c.writeMutex.Lock()
+	_, err = c.conn.Write(catalog.Frame(c.codec, msg))
+	c.writeMutex.Unlock()
+	if err != nil {
+		c.fail(err)
+	}
+
+	r := <-ch
+	return r.resp, r.err
+}
+
+// read delivers the responses to the waiting requests until the connection breaks
+func (c *conn) read(reader *bufio.Reader) {
+	for {
+		message, err := catalog.ReadFrame(reader, c.codec)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		var resp catalog.Response
+		err = c.codec.Unmarshal(message, &resp)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		c.mutex.Lock()
+		ch, ok := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.mutex.Unlock()
+		if ok {
+			ch <- result{resp: &resp}
+		}
+	}
+}
+
+// fail breaks the connection, the waiting requests get the error
+func (c *conn) fail(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err == nil {
+		c.err = err
+	}
+	c.conn.Close()
+	for id, ch := range c.pending {
+		ch <- result{err: c.err}
+		delete(c.pending, id)
+	}
+}
+
+func (c *conn) broken() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err != nil
+}
+
+func (c *conn) close() error {
+	c.fail(net.ErrClosed)
+	return nil
+}
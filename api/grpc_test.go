@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/PumpkinSeed/catalog"
+	"github.com/PumpkinSeed/catalog/api/catalogpb"
+)
+
+func TestGRPC(t *testing.T) {
+	var server = catalog.NewServer("127.0.0.1:0", nil, &sync.RWMutex{}, catalog.WithGRPC("127.0.0.1:0"))
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	conn, err := grpc.NewClient(server.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var client = catalogpb.NewCatalogClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	watch, err := client.Watch(ctx, &catalogpb.WatchRequest{Name: "webserver"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stream is set up when its headers arrived
+	if _, err := watch.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	registered, err := client.Register(ctx, &catalogpb.RegisterRequest{Name: "webserver", Address: "localhost", Port: 8080, Tags: []string{"web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Register(ctx, &catalogpb.RegisterRequest{Name: "auth", Address: "localhost", Port: 8001}); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := watch.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Kind != catalogpb.WatchEvent_KIND_REGISTER || event.Service.Id != registered.Id {
+		t.Errorf("The event should be the register of %d, instead of %v", registered.Id, event)
+	}
+
+	service, err := client.Service(ctx, &catalogpb.ServiceRequest{Service: &catalogpb.ServiceRequest_Name{Name: "webserver"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Service.Id != registered.Id || service.Service.Port != 8080 {
+		t.Errorf("The service should be webserver:8080, instead of %v", service.Service)
+	}
+
+	services, err := client.Services(ctx, &catalogpb.ServicesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if services.Total != 2 {
+		t.Errorf("Total should be 2, instead of %d", services.Total)
+	}
+
+	if _, err := client.Deregister(ctx, &catalogpb.DeregisterRequest{Service: &catalogpb.DeregisterRequest_Id{Id: registered.Id}}); err != nil {
+		t.Fatal(err)
+	}
+	event, err = watch.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Kind != catalogpb.WatchEvent_KIND_DEREGISTER {
+		t.Errorf("The event should be a deregister, instead of %v", event)
+	}
+	_, err = client.Service(ctx, &catalogpb.ServiceRequest{Service: &catalogpb.ServiceRequest_Id{Id: registered.Id}})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Code should be %v, instead of %v", codes.NotFound, err)
+	}
+}
+
+func TestGRPCToken(t *testing.T) {
+	var server = catalog.NewServer("127.0.0.1:0", nil, &sync.RWMutex{}, catalog.WithACL("master"), catalog.WithGRPC("127.0.0.1:0"))
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	conn, err := grpc.NewClient(server.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var client = catalogpb.NewCatalogClient(conn)
+
+	var request = &catalogpb.RegisterRequest{Name: "webserver", Address: "localhost", Port: 8080}
+	if _, err := client.Register(context.Background(), request); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Code should be %v, instead of %v", codes.PermissionDenied, err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), catalog.GRPCTokenKey, "master")
+	if _, err := client.Register(ctx, request); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/catalog"
+)
+
+func TestMultipleListeners(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "catalog.sock")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no TCP bindAddr, only the unix socket and the listener of the caller
+	var server = catalog.NewServer("", nil, &sync.RWMutex{}, catalog.WithUnixSocket(socket, 0600), catalog.WithListener(ln))
+	var listenErr = make(chan error, 1)
+	go func() {
+		listenErr <- server.Listen()
+	}()
+
+	<-server.Ready()
+
+	var unix = NewCatalog("unix://" + socket)
+	defer unix.Close()
+	id, err := unix.Register("local", "localhost", 8080, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Permissions of the socket should be 0600, instead of %v", info.Mode().Perm())
+	}
+
+	var tcp = NewCatalog(ln.Addr().String())
+	defer tcp.Close()
+	service, err := tcp.Service(&id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Name != "local" {
+		t.Errorf("Service should be registered through the unix socket, instead of %+v", service)
+	}
+
+	server.Close()
+	select {
+	case err := <-listenErr:
+		if err != nil {
+			t.Errorf("Listen should return nil after Close, instead of %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Listen should return after Close")
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("Socket file should be removed, instead of %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PumpkinSeed/catalog"
+)
+
+func TestMutualTLS(t *testing.T) {
+	ca, caKey := testCertificate(t, nil, nil, true)
+	serverCert, serverKey := testCertificate(t, ca, caKey, false)
+	clientCert, clientKey := testCertificate(t, ca, caKey, false)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	var server = catalog.NewServer("127.0.0.1:0", nil, &sync.RWMutex{}, catalog.WithTLS(&tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	addr := server.Addr().String()
+
+	var instance = NewCatalog(addr, WithTLS(&tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}},
+	}))
+	defer instance.Close()
+	if _, err := instance.Register("secure", "localhost", 8443, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	services, err := instance.Services()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Name != "secure" {
+		t.Errorf("There should be the secure service, instead of %v", services)
+	}
+
+	// the clients without certificate and without TLS are rejected
+	for name, option := range map[string]Option{
+		"without certificate": WithTLS(&tls.Config{RootCAs: pool}),
+		"without TLS":         WithCodec(catalog.CodecJSON),
+	} {
+		var rejected = NewCatalog(addr, option)
+		if _, err := rejected.Services(); err == nil {
+			t.Errorf("The client %s should be rejected", name)
+		}
+		rejected.Close()
+	}
+}
+
+// testCertificate generates a certificate for 127.0.0.1 signed by the parent,
+// self-signed if the parent is nil
+func testCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "catalog test"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
@@ -0,0 +1,251 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Types of the built-in healthchecks
+const (
+	CheckTCP  = "tcp"
+	CheckHTTP = "http"
+	CheckGRPC = "grpc"
+	CheckExec = "exec"
+)
+
+// defaultCheckTimeout is the timeout of a built-in healthcheck without Timeout
+const defaultCheckTimeout = 1 * time.Second
+
+// maxCheckOutput is the length of the output kept from an exec check, like in Consul
+const maxCheckOutput = 4096
+
+var (
+	errUnexpectedStatus  = errors.New("unexpected status code")
+	errUnexpectedBody    = errors.New("response body doesn't match")
+	errUnexpectedExit    = errors.New("unexpected exit code")
+	errServiceNotServing = errors.New("service not serving")
+)
+
+// CheckDefinition is a declarative healthcheck run by the server itself
+type CheckDefinition struct {
+	// Name of the check, default is the Type
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Weight of the check in the weighted health policy, default is 1
+	Weight int `json:"weight"`
+
+	// Address of the tcp and grpc checks, default is the address of the service
+	Address string `json:"address"`
+
+	// URL of the http check, default is http://<address of the service>/
+	URL string `json:"url"`
+	// Status is the expected status code of the http check, default is 200
+	Status int `json:"status"`
+	// Body is a substring of the expected http response body
+	Body string `json:"body"`
+
+	// GRPCService is the service name sent in the grpc health request
+	GRPCService string `json:"grpc_service"`
+
+	// Command and the expected ExitCode of the exec check
+	Command  []string `json:"command"`
+	ExitCode int      `json:"exit_code"`
+
+	Interval time.Duration `json:"interval"`
+	Timeout  time.Duration `json:"timeout"`
+
+	// flap damping, see the fields of Check
+	SuccessBeforePassing   int `json:"success_before_passing"`
+	FailuresBeforeWarning  int `json:"failures_before_warning"`
+	FailuresBeforeCritical int `json:"failures_before_critical"`
+}
+
+// Validate checks the required fields of the definition
+func (d *CheckDefinition) Validate() error {
+	switch d.Type {
+	case CheckTCP, CheckHTTP, CheckGRPC:
+		return nil
+	case CheckExec:
+		if len(d.Command) == 0 {
+			return ErrInvalidCheck
+		}
+		return nil
+	}
+
+	return ErrUnknownCheckType
+}
+
+// Check builds the named check of the definition for the service on address
+func (d *CheckDefinition) Check(address string) (Check, error) {
+	f, err := d.CheckFunc(address)
+	if err != nil {
+		return Check{}, err
+	}
+
+	name := d.Name
+	if name == "" {
+		name = d.Type
+	}
+	return Check{
+		Name:     name,
+		Func:     f,
+		Interval: d.Interval,
+		Timeout:  d.timeout(),
+		Weight:   d.Weight,
+
+		SuccessBeforePassing:   d.SuccessBeforePassing,
+		FailuresBeforeWarning:  d.FailuresBeforeWarning,
+		FailuresBeforeCritical: d.FailuresBeforeCritical,
+	}, nil
+}
+
+func (d *CheckDefinition) timeout() time.Duration {
+	if d.Timeout <= 0 {
+		return defaultCheckTimeout
+	}
+	return d.Timeout
+}
+
+// CheckFunc builds the healthcheck of the definition for the service on address
+func (d *CheckDefinition) CheckFunc(address string) (CheckFunc, error) {
+	err := d.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Address != "" {
+		address = d.Address
+	}
+
+	var check func(ctx context.Context) error
+	switch d.Type {
+	case CheckTCP:
+		check = tcpCheck(address)
+	case CheckHTTP:
+		url := d.URL
+		if url == "" {
+			url = "http://" + address + "/"
+		}
+		status := d.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		check = httpCheck(url, status, d.Body)
+	case CheckGRPC:
+		check = grpcCheck(address, d.GRPCService)
+	case CheckExec:
+		check = execCheck(d.Command, d.ExitCode)
+	}
+
+	// the error is the output of the failing check, a passing check might have output too
+	return func(ctx context.Context) (Status, error) {
+		err := check(ctx)
+		if _, ok := err.(checkOutput); ok {
+			return StatusPassing, err
+		}
+		if err != nil {
+			return StatusCritical, err
+		}
+		return StatusPassing, nil
+	}, nil
+}
+
+func tcpCheck(address string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+func httpCheck(url string, status int, body string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != status {
+			return fmt.Errorf("%w: %d", errUnexpectedStatus, resp.StatusCode)
+		}
+		if body == "" {
+			return nil
+		}
+		content, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(content), body) {
+			return errUnexpectedBody
+		}
+		return nil
+	}
+}
+
+func grpcCheck(address string, service string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+			return errServiceNotServing
+		}
+		return nil
+	}
+}
+
+// execCheck runs the command, its combined output is the output of the check
+func execCheck(command []string, exitCode int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		out, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+		code := 0
+		if err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return err
+			}
+			code = exitErr.ExitCode()
+		}
+
+		output := strings.TrimSpace(string(out))
+		if len(output) > maxCheckOutput {
+			output = output[:maxCheckOutput]
+		}
+		if code != exitCode {
+			if output == "" {
+				return fmt.Errorf("%w: %d", errUnexpectedExit, code)
+			}
+			return fmt.Errorf("%w: %d: %s", errUnexpectedExit, code, output)
+		}
+		if output == "" {
+			return nil
+		}
+		return checkOutput(output)
+	}
+}
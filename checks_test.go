@@ -0,0 +1,119 @@
+package catalog
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestCheckDefinitions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			w.Write([]byte("status: ready"))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	httpAddr := strings.TrimPrefix(ts.URL, "http://")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("up", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
+	go grpcServer.Serve(ln)
+	defer grpcServer.Stop()
+
+	var cases = []struct {
+		name       string
+		definition CheckDefinition
+		address    string
+		alive      bool
+	}{
+		{"tcp up", CheckDefinition{Type: CheckTCP}, httpAddr, true},
+		{"tcp down", CheckDefinition{Type: CheckTCP, Address: "127.0.0.1:1"}, httpAddr, false},
+		{"http ready", CheckDefinition{Type: CheckHTTP, URL: ts.URL + "/ready", Body: "ready"}, "", true},
+		{"http body mismatch", CheckDefinition{Type: CheckHTTP, URL: ts.URL + "/ready", Body: "live"}, "", false},
+		{"http status", CheckDefinition{Type: CheckHTTP}, httpAddr, false},
+		{"http expected status", CheckDefinition{Type: CheckHTTP, Status: http.StatusServiceUnavailable}, httpAddr, true},
+		{"grpc serving", CheckDefinition{Type: CheckGRPC, GRPCService: "up"}, ln.Addr().String(), true},
+		{"grpc not serving", CheckDefinition{Type: CheckGRPC, GRPCService: "down"}, ln.Addr().String(), false},
+		{"exec success", CheckDefinition{Type: CheckExec, Command: []string{"true"}}, "", true},
+		{"exec exit code", CheckDefinition{Type: CheckExec, Command: []string{"false"}, ExitCode: 1}, "", true},
+		{"exec failure", CheckDefinition{Type: CheckExec, Command: []string{"false"}}, "", false},
+	}
+
+	for _, c := range cases {
+		f, err := c.definition.CheckFunc(c.address)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		status, err := f(context.Background())
+		alive := status == StatusPassing
+		if _, ok := err.(checkOutput); alive && err != nil && !ok {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if !alive && err == nil {
+			t.Errorf("%s: failing check should have output", c.name)
+		}
+		if alive != c.alive {
+			t.Errorf("%s: alive should be %v, instead of %v", c.name, c.alive, alive)
+		}
+	}
+}
+
+func TestCheckDefinitionValidate(t *testing.T) {
+	if err := (&CheckDefinition{Type: "udp"}).Validate(); err != ErrUnknownCheckType {
+		t.Errorf("Error should be %v, instead of %v", ErrUnknownCheckType, err)
+	}
+	if err := (&CheckDefinition{Type: CheckExec}).Validate(); err != ErrInvalidCheck {
+		t.Errorf("Error should be %v, instead of %v", ErrInvalidCheck, err)
+	}
+}
+
+func TestExecChecks(t *testing.T) {
+	var exec = CheckDefinition{Type: CheckExec, Command: []string{"sh", "-c", "echo accepting connections"}}
+
+	s := newTestServer(t)
+	var registerResp RegisterResponse
+	err := s.register(&RegisterRequest{Name: "db", Address: localhost, Port: 9015, Check: &exec}, &registerResp)
+	if err != nil || registerResp.Success || registerResp.Error != ErrExecChecksDisabled.Error() {
+		t.Errorf("Exec check should be rejected, instead of %v %s", err, registerResp.Error)
+	}
+
+	s.execChecks = true
+	err = s.register(&RegisterRequest{Name: "db", Address: localhost, Port: 9015, Check: &exec}, &registerResp)
+	if err != nil || !registerResp.Success {
+		t.Fatalf("Exec check should be accepted, instead of %v %s", err, registerResp.Error)
+	}
+	ss, err := s.storage.Service(&registerResp.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := ss.Checks[CheckExec]
+	if result == nil || result.Status != StatusPassing || result.Output != "accepting connections" {
+		t.Errorf("Check should be passing with the output of the command, instead of %+v", result)
+	}
+
+	f, err := (&CheckDefinition{Type: CheckExec, Command: []string{"sh", "-c", "echo no response; exit 2"}}).CheckFunc("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := f(context.Background())
+	if status != StatusCritical || err == nil || !strings.HasSuffix(err.Error(), ": 2: no response") {
+		t.Errorf("Check should be critical with the output of the command, instead of %s %v", status, err)
+	}
+}
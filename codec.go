@@ -0,0 +1,155 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Names of the codecs
+const (
+	CodecJSON    = "json"
+	CodecMsgpack = "msgpack"
+	CodecCBOR    = "cbor"
+)
+
+// maxFrameSize is the maximum size of a length-prefixed frame
+const maxFrameSize = 16 << 20
+
+var errFrameTooLarge = errors.New("frame too large")
+
+// Codec encodes the messages of a connection, the binary codecs are negotiated
+// with the Hello command and use length-prefixed frames
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var codecs = map[string]Codec{
+	CodecJSON:    jsonCodec{},
+	CodecMsgpack: msgpackCodec{},
+	CodecCBOR:    newCBORCodec(),
+}
+
+// CodecByName returns the codec of the name
+func CodecByName(name string) (Codec, bool) {
+	codec, ok := codecs[name]
+	return codec, ok
+}
+
+// Frame wraps the encoded message for the wire, the JSON messages are delimited
+// by newline and the binary ones prefixed by their big-endian uint32 length
+func Frame(codec Codec, msg []byte) []byte {
+	if codec.Name() == CodecJSON {
+		return append(msg, delimiterByte)
+	}
+	var frame = make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+	copy(frame[4:], msg)
+	return frame
+}
+
+// ReadFrame reads the next message of the codec, the JSON messages keep their delimiter
+func ReadFrame(r *bufio.Reader, codec Codec) ([]byte, error) {
+	if codec.Name() == CodecJSON {
+		return r.ReadBytes(delimiterByte)
+	}
+
+	var size [4]byte
+	_, err := io.ReadFull(r, size[:])
+	if err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length > maxFrameSize {
+		return nil, errFrameTooLarge
+	}
+	var msg = make([]byte, length)
+	_, err = io.ReadFull(r, msg)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// unframe returns the message of a frame written by Frame
+func unframe(codec Codec, frame []byte) []byte {
+	if codec.Name() == CodecJSON {
+		return frame
+	}
+	return frame[4:]
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return CodecJSON
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// msgpackCodec uses the json tags of the models
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string {
+	return CodecMsgpack
+}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	err := enc.Encode(v)
+	return buf.Bytes(), err
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// cborCodec keeps the nanoseconds of the timestamps and decodes
+// the maps like encoding/json
+type cborCodec struct {
+	enc cbor.EncMode
+	dec cbor.DecMode
+}
+
+func newCBORCodec() cborCodec {
+	enc, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	dec, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return cborCodec{enc: enc, dec: dec}
+}
+
+func (cborCodec) Name() string {
+	return CodecCBOR
+}
+
+func (c cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return c.enc.Marshal(v)
+}
+
+func (c cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return c.dec.Unmarshal(data, v)
+}
@@ -0,0 +1,248 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFrame(t *testing.T) {
+	for _, name := range []string{CodecJSON, CodecMsgpack, CodecCBOR} {
+		codec, _ := CodecByName(name)
+		msg, err := codec.Marshal(Request{Cmd: Services, ID: "1", Version: ProtocolV2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stream = append(Frame(codec, msg), Frame(codec, msg)...)
+
+		reader := bufio.NewReader(bytes.NewReader(stream))
+		for i := 0; i < 2; i++ {
+			frame, err := ReadFrame(reader, codec)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			req, err := decodeRequest(codec, frame)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if req.Cmd != Services || req.ID != "1" || req.Version != ProtocolV2 {
+				t.Errorf("%s: request should round trip, instead of %+v", name, req)
+			}
+		}
+	}
+
+	// the length prefix can't allocate more than maxFrameSize
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], maxFrameSize+1)
+	codec, _ := CodecByName(CodecMsgpack)
+	_, err := ReadFrame(bufio.NewReader(bytes.NewReader(size[:])), codec)
+	if err != errFrameTooLarge {
+		t.Errorf("Error should be %v, instead of %v", errFrameTooLarge, err)
+	}
+}
+
+func TestBinaryCodecs(t *testing.T) {
+	for _, name := range []string{CodecMsgpack, CodecCBOR} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			conn := serveTestConn(t, s)
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+
+			// the handshake is JSON, the negotiated codec is used from the next message
+			helloJSON, _ := json.Marshal(HelloRequest{Versions: []int{ProtocolV2}, Codecs: []string{"unknown", name}})
+			rJSON, _ := json.Marshal(Request{Cmd: Hello, Version: ProtocolV2, Payload: helloJSON})
+			if _, err := conn.Write(append(rJSON, delimiterByte)); err != nil {
+				t.Fatal(err)
+			}
+			message, err := reader.ReadBytes(delimiterByte)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var resp Response
+			if err := json.Unmarshal(message, &resp); err != nil {
+				t.Fatal(err)
+			}
+			var helloResp HelloResponse
+			if err := json.Unmarshal(resp.Payload, &helloResp); err != nil {
+				t.Fatal(err)
+			}
+			if helloResp.Codec != name {
+				t.Fatalf("Codec should be %s, instead of %s", name, helloResp.Codec)
+			}
+
+			codec, _ := CodecByName(name)
+			var registerResp RegisterResponse
+			roundTrip(t, conn, reader, codec, Register, RegisterRequest{Name: "binary", Address: localhost, Port: 9015, Tags: []string{"bin"}}, &registerResp)
+			if !registerResp.Success {
+				t.Fatalf("Register should succeed, instead of %s", registerResp.Error)
+			}
+
+			serviceName := "binary"
+			var serviceResp ServiceResponse
+			roundTrip(t, conn, reader, codec, Service, ServiceRequest{Name: &serviceName}, &serviceResp)
+			service := serviceResp.Service
+			if !serviceResp.Success || service.ID != registerResp.ID || service.Port != 9015 || len(service.Tags) != 1 || service.RegisteredAt.IsZero() {
+				t.Errorf("Service should round trip, instead of %+v", serviceResp)
+			}
+		})
+	}
+}
+
+// serveTestConn serves a single connection of s
+func serveTestConn(t testing.TB, s *server) net.Conn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err == nil {
+			s.serve(conn)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// roundTrip sends the v2 request in a frame of the codec and decodes the payload of its response into out
+func roundTrip(t testing.TB, conn net.Conn, reader *bufio.Reader, codec Codec, cmd Command, payload interface{}, out interface{}) {
+	p, err := codec.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := codec.Marshal(Request{Cmd: cmd, Version: ProtocolV2, Payload: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(Frame(codec, msg)); err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := ReadFrame(reader, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := codec.Unmarshal(frame, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := codec.Unmarshal(resp.Payload, out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// benchmarkServices is a services response of n services with a check
+func benchmarkServices(n int) ServicesResponse {
+	var resp = ServicesResponse{Success: true, Total: n}
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		resp.Services = append(resp.Services, ServiceSpec{
+			ID:           Identifier(i),
+			Name:         "service" + strconv.Itoa(i),
+			Host:         localhost,
+			Port:         8000 + i,
+			Address:      localhost + ":" + strconv.Itoa(8000+i),
+			Tags:         []string{"web", "http"},
+			RegisteredAt: now,
+			Healthcheck:  true,
+			IsAlive:      true,
+			Status:       StatusPassing,
+			Checks: map[string]*CheckResult{
+				CheckHTTP: {Status: StatusPassing, LastChecked: now, LastChange: now},
+			},
+		})
+	}
+	return resp
+}
+
+// BenchmarkEncodeServices encodes the services response the way the server does in each
+// version and codec, v1 embeds the JSON payload in a JSON string
+func BenchmarkEncodeServices(b *testing.B) {
+	var services = benchmarkServices(100)
+	var cases = []struct {
+		name    string
+		codec   string
+		version int
+	}{
+		{"json-v1", CodecJSON, ProtocolV1},
+		{"json-v2", CodecJSON, ProtocolV2},
+		{"msgpack", CodecMsgpack, ProtocolV2},
+		{"cbor", CodecCBOR, ProtocolV2},
+	}
+	for _, c := range cases {
+		codec, _ := CodecByName(c.codec)
+		req := Request{Version: c.version, codec: codec}
+		b.Run(c.name, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				payload, err := req.encodePayload(&services)
+				if err != nil {
+					b.Fatal(err)
+				}
+				frame, err := req.encodeResponse(req.response(payload))
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(frame)
+			}
+			b.ReportMetric(float64(size), "bytes/frame")
+		})
+	}
+}
+
+// BenchmarkDecodeServices decodes the services response the way the api does in each version and codec
+func BenchmarkDecodeServices(b *testing.B) {
+	var services = benchmarkServices(100)
+	var cases = []struct {
+		name    string
+		codec   string
+		version int
+	}{
+		{"json-v1", CodecJSON, ProtocolV1},
+		{"json-v2", CodecJSON, ProtocolV2},
+		{"msgpack", CodecMsgpack, ProtocolV2},
+		{"cbor", CodecCBOR, ProtocolV2},
+	}
+	for _, c := range cases {
+		codec, _ := CodecByName(c.codec)
+		req := Request{Version: c.version, codec: codec}
+		payload, err := req.encodePayload(&services)
+		if err != nil {
+			b.Fatal(err)
+		}
+		frame, err := req.encodeResponse(req.response(payload))
+		if err != nil {
+			b.Fatal(err)
+		}
+		msg := unframe(codec, frame)
+
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var resp Response
+				if err := codec.Unmarshal(msg, &resp); err != nil {
+					b.Fatal(err)
+				}
+				var servicesResp ServicesResponse
+				if c.version < ProtocolV2 {
+					err = json.Unmarshal([]byte(resp.Resp), &servicesResp)
+				} else {
+					err = codec.Unmarshal(resp.Payload, &servicesResp)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
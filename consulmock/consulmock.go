@@ -2,6 +2,7 @@ package consulmock
 
 import (
 	"bufio"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -27,10 +28,36 @@ type Catalog interface {
 
 type catalogmock struct {
 	addr string
+	// tlsConfig of the connections, nil means plain TCP
+	tlsConfig *tls.Config
+	// token is the default ACL token of the requests
+	token string
 }
 
-func NewCatalog(addr string) Catalog {
-	return &catalogmock{addr: addr}
+// Option configures the Catalog
+type Option func(a *catalogmock)
+
+// WithTLS connects to the catalog over TLS, like the TLSConfig of the consul client
+func WithTLS(config *tls.Config) Option {
+	return func(a *catalogmock) {
+		a.tlsConfig = config
+	}
+}
+
+// WithToken sets the default ACL token of the requests, like the Token of the consul
+// client config sent in X-Consul-Token. The Token of the WriteOptions overrides it.
+func WithToken(secretID string) Option {
+	return func(a *catalogmock) {
+		a.token = secretID
+	}
+}
+
+func NewCatalog(addr string, options ...Option) Catalog {
+	a := &catalogmock{addr: addr}
+	for _, option := range options {
+		option(a)
+	}
+	return a
 }
 
 func (a *catalogmock) Register(req *api.CatalogRegistration, q *api.WriteOptions) (*api.WriteMeta, error) {
@@ -44,14 +71,18 @@ func (a *catalogmock) Register(req *api.CatalogRegistration, q *api.WriteOptions
 	}
 
 	var mainRequest = catalog.Request{
-		Cmd: catalog.Register,
-		Req: string(rrJSON),
+		Cmd:   catalog.Register,
+		Req:   string(rrJSON),
+		Token: a.writeToken(q),
 	}
 
 	resp, err := a.do(mainRequest)
 	if err != nil {
 		return nil, err
 	}
+	if resp.Code == catalog.CodePermissionDenied {
+		return nil, catalog.ErrPermissionDenied
+	}
 
 	var respRegister catalog.RegisterResponse
 	err = json.Unmarshal([]byte(resp.Resp), &respRegister)
@@ -91,6 +122,14 @@ func (a *catalogmock) Service(service, tag string, q *api.QueryOptions) ([]*api.
 	return nil, nil, nil
 }
 
+// writeToken returns the token of the write options, the default token without it
+func (a *catalogmock) writeToken(q *api.WriteOptions) string {
+	if q != nil && q.Token != "" {
+		return q.Token
+	}
+	return a.token
+}
+
 func (a *catalogmock) translateRegisterRequest(req *api.CatalogRegistration, rr *catalog.RegisterRequest) error {
 	rr.Name = req.Service.ID
 	rr.Address = req.Service.Address
@@ -108,13 +147,21 @@ func (a *catalogmock) translateDeregisterRequest(req *api.CatalogDeregistration,
 	return nil
 }
 
+// dial connects to the catalog over TLS if it is configured
+func (a *catalogmock) dial() (net.Conn, error) {
+	if a.tlsConfig != nil {
+		return tls.Dial("tcp", a.addr, a.tlsConfig)
+	}
+	return net.Dial("tcp", a.addr)
+}
+
 func (a *catalogmock) do(req catalog.Request) (*catalog.Response, error) {
 	rJSON, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
-	conn, err := net.Dial("tcp", a.addr)
+	conn, err := a.dial()
 	if err != nil {
 		return nil, err
 	}
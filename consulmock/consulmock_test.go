@@ -12,7 +12,7 @@ import (
 
 var (
 	testCatalogInstance Catalog
-	binAddr             = "127.0.0.1:8889"
+	binAddr             string
 	testServices        = []*struct {
 		name       string
 		host       string
@@ -43,10 +43,12 @@ var (
 )
 
 func init() {
-	var server = catalog.NewServer(binAddr, nil, &sync.RWMutex{})
-	go func() {
-		panic(server.Listen())
-	}()
+	var server = catalog.NewServer("127.0.0.1:0", nil, &sync.RWMutex{})
+	err := server.Start()
+	if err != nil {
+		panic(err)
+	}
+	binAddr = server.Addr().String()
 	startServices()
 }
 
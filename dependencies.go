@@ -0,0 +1,154 @@
+package catalog
+
+import (
+	"sort"
+	"time"
+)
+
+// Dependency is a dependency of a service on the instances of another service
+type Dependency struct {
+	Name string `json:"name"`
+	// Required dependency without healthy instances makes the service critical,
+	// an optional one makes it warning
+	Required bool `json:"required"`
+}
+
+// DependencyGraph maps the name of the services to the names of their dependencies
+type DependencyGraph map[string][]string
+
+// cycle reports whether the dependencies of the service named name close a cycle in the graph
+func (g DependencyGraph) cycle(name string, dependencies []Dependency) bool {
+	var visited = make(map[string]bool)
+	var reaches func(from string) bool
+	reaches = func(from string) bool {
+		if from == name {
+			return true
+		}
+		if visited[from] {
+			return false
+		}
+		visited[from] = true
+		for _, next := range g[from] {
+			if reaches(next) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, dependency := range dependencies {
+		if reaches(dependency.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDependencies replaces the dependencies of the service, the dependencies closing
+// a cycle between the service names are refused with ErrDependencyCycle
+func (s *storage) SetDependencies(id Identifier, dependencies []Dependency) error {
+	for _, dependency := range dependencies {
+		if dependency.Name == "" {
+			return ErrInvalidDependency
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	service, ok := s.services[id]
+	if !ok {
+		return ErrUndefinedService
+	}
+	if s.dependencyGraph(service).cycle(service.Name, dependencies) {
+		return ErrDependencyCycle
+	}
+	service.Dependencies = append([]Dependency(nil), dependencies...)
+
+	s.dispatcher.emit(EventUpdate, service)
+	s.propagate(time.Now())
+	return nil
+}
+
+// DependencyGraph returns the dependencies of the registered services by name
+func (s *storage) DependencyGraph() DependencyGraph {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.dependencyGraph(nil)
+}
+
+// dependencyGraph builds the graph of the services without the dependencies
+// of the excluded one, the mutex should be locked
+func (s *storage) dependencyGraph(excluded *ServiceSpec) DependencyGraph {
+	var names = make(map[string]map[string]bool)
+	for _, service := range s.services {
+		if _, ok := names[service.Name]; !ok {
+			names[service.Name] = make(map[string]bool)
+		}
+		if service == excluded {
+			continue
+		}
+		for _, dependency := range service.Dependencies {
+			names[service.Name][dependency.Name] = true
+		}
+	}
+
+	var graph = make(DependencyGraph, len(names))
+	for name, dependencies := range names {
+		var sorted = make([]string, 0, len(dependencies))
+		for dependency := range dependencies {
+			sorted = append(sorted, dependency)
+		}
+		sort.Strings(sorted)
+		graph[name] = sorted
+	}
+	return graph
+}
+
+// propagate updates the dependency status of the services until the outages cascaded
+// through the graph, the mutex should be locked. The services changed by their
+// dependencies emit the health change.
+func (s *storage) propagate(now time.Time) {
+	// the graph is acyclic, so the longest chain is shorter than the number of services
+	for i := 0; i <= len(s.services); i++ {
+		var healthy = make(map[string]bool)
+		for _, service := range s.services {
+			if service.Status != StatusCritical {
+				healthy[service.Name] = true
+			}
+		}
+
+		var changed bool
+		for _, service := range s.services {
+			status := dependencyStatus(service.Dependencies, healthy)
+			if status == service.DependencyStatus {
+				continue
+			}
+			service.DependencyStatus = status
+			changed = true
+			if service.updateStatus(now) {
+				s.dispatcher.emit(EventHealthChange, service)
+			}
+		}
+		if !changed {
+			return
+		}
+	}
+}
+
+// dependencyStatus is critical when a required dependency has no healthy instances,
+// warning when an optional one and empty when every dependency is healthy
+func dependencyStatus(dependencies []Dependency, healthy map[string]bool) Status {
+	var status Status
+	for _, dependency := range dependencies {
+		if healthy[dependency.Name] {
+			continue
+		}
+		if dependency.Required {
+			return StatusCritical
+		}
+		status = StatusWarning
+	}
+	return status
+}
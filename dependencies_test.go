@@ -0,0 +1,110 @@
+package catalog
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDependencyCascade(t *testing.T) {
+	storage := NewStorage(nil, time.Hour, &sync.RWMutex{})
+	defer storage.Close()
+
+	var ids = make(map[string]Identifier)
+	for _, name := range []string{"db", "cache", "api", "web"} {
+		id, err := storage.Register(name, localhost, 9010, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = id
+	}
+	if err := storage.SetDependencies(ids["api"], []Dependency{{Name: "db", Required: true}}); err != nil {
+		t.Fatal(err)
+	}
+	err := storage.SetDependencies(ids["web"], []Dependency{{Name: "api", Required: true}, {Name: "cache"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.SetDependencies(ids["db"], []Dependency{{Name: "web"}}); err != ErrDependencyCycle {
+		t.Errorf("Error should be %v, instead of %v", ErrDependencyCycle, err)
+	}
+	if err := storage.SetDependencies(ids["db"], []Dependency{{Name: "db"}}); err != ErrDependencyCycle {
+		t.Errorf("Self dependency should be %v, instead of %v", ErrDependencyCycle, err)
+	}
+
+	graph := storage.DependencyGraph()
+	expected := DependencyGraph{"db": {}, "cache": {}, "api": {"db"}, "web": {"api", "cache"}}
+	if !reflect.DeepEqual(graph, expected) {
+		t.Errorf("Graph should be %v, instead of %v", expected, graph)
+	}
+
+	var statusOf = func(name string) Status {
+		id := ids[name]
+		ss, err := storage.Service(&id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ss.Status
+	}
+
+	// the outage of the db cascades to the web
+	if err := storage.SetHealth(ids["db"], StatusCritical, "outage", 0); err != nil {
+		t.Fatal(err)
+	}
+	if statusOf("api") != StatusCritical || statusOf("web") != StatusCritical {
+		t.Errorf("Outage should cascade, instead of api %s web %s", statusOf("api"), statusOf("web"))
+	}
+
+	if err := storage.ClearOverride(ids["db"]); err != nil {
+		t.Fatal(err)
+	}
+	if statusOf("api") != "" || statusOf("web") != "" {
+		t.Errorf("Recovery should cascade, instead of api %s web %s", statusOf("api"), statusOf("web"))
+	}
+
+	// the optional cache degrades the web
+	if err := storage.Deregister(nil, strPtr("cache")); err != nil {
+		t.Fatal(err)
+	}
+	if statusOf("web") != StatusWarning || statusOf("api") != "" {
+		t.Errorf("Web should be degraded, instead of web %s api %s", statusOf("web"), statusOf("api"))
+	}
+}
+
+func TestDependencyOutageIsNotReaped(t *testing.T) {
+	storage := NewStorage(nil, time.Hour, &sync.RWMutex{})
+	defer storage.Close()
+
+	id, err := storage.Register("frontend", localhost, 9014, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SetDeregisterCriticalAfter(id, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	err = storage.AddCheck(id, Check{Name: "tcp", Interval: 10 * time.Millisecond, Func: AdaptCheck(func() (bool, error) {
+		return true, nil
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SetDependencies(id, []Dependency{{Name: "backend", Required: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the missing backend makes the frontend critical, its own check keeps passing
+	time.Sleep(100 * time.Millisecond)
+	ss, err := storage.Service(&id, nil)
+	if err != nil {
+		t.Fatal("Service with passing checks shouldn't be deregistered")
+	}
+	if ss.Status != StatusCritical || ss.DependencyStatus != StatusCritical {
+		t.Errorf("Outage should be reported, instead of %s %s", ss.Status, ss.DependencyStatus)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
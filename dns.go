@@ -0,0 +1,230 @@
+package catalog
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// DNSDomain is the domain of the DNS interface, the services are <name>.service.catalog.
+// or <tag>.<name>.service.catalog., the SRV targets of the IP hosts are <hex ip>.addr.catalog.
+const DNSDomain = "catalog."
+
+// WithDNS answers the DNS queries of the healthy services on addr over UDP and TCP,
+// both on the same port. The answers have zero TTL, like the in-memory catalog they change
+// with every registration. With ACLs only the services readable by the WithDNSToken are answered.
+func WithDNS(addr string) ServerOption {
+	return func(s *server) {
+		s.dnsAddr = addr
+	}
+}
+
+// WithDNSToken is the token of the DNS queries when the ACLs are enabled
+func WithDNSToken(secretID string) ServerOption {
+	return func(s *server) {
+		s.dnsToken = secretID
+	}
+}
+
+// listenDNS opens the UDP and TCP listeners of the DNS interface, nil if it isn't configured.
+// The TCP listener takes the port of the UDP one, so :0 ends up on the same port.
+func (s *server) listenDNS() ([]*dns.Server, error) {
+	if s.dnsAddr == "" {
+		return nil, nil
+	}
+	pc, err := net.ListenPacket("udp", s.dnsAddr)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+
+	var handler = dns.HandlerFunc(s.serveDNS)
+	return []*dns.Server{
+		{PacketConn: pc, Handler: handler},
+		{Listener: ln, Handler: handler},
+	}, nil
+}
+
+// DNSAddr is the address of the DNS interface, the UDP and TCP ports are the same
+func (s *server) DNSAddr() net.Addr {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.dnsServers) == 0 {
+		return nil
+	}
+	return s.dnsServers[0].PacketConn.LocalAddr()
+}
+
+// closeDNS shuts the DNS servers down, their connections are closed
+// even if they weren't started yet
+func closeDNS(servers []*dns.Server) {
+	for _, ds := range servers {
+		ds.Shutdown()
+		if ds.PacketConn != nil {
+			ds.PacketConn.Close()
+		}
+		if ds.Listener != nil {
+			ds.Listener.Close()
+		}
+	}
+}
+
+// serveDNS answers the A, AAAA and SRV queries of the services, an unknown name or
+// a service without healthy instances is NXDOMAIN
+func (s *server) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
+	var m = new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	defer w.WriteMsg(m)
+
+	if len(r.Question) == 0 {
+		m.Rcode = dns.RcodeFormatError
+		return
+	}
+	q := r.Question[0]
+	name := strings.ToLower(q.Name)
+	if !dns.IsSubDomain(DNSDomain, name) {
+		m.Rcode = dns.RcodeRefused
+		return
+	}
+
+	labels := dns.SplitDomainName(strings.TrimSuffix(name, DNSDomain))
+	switch {
+	case len(labels) == 2 && labels[1] == "addr":
+		ip := decodeAddr(labels[0])
+		if ip == nil {
+			m.Rcode = dns.RcodeNameError
+			return
+		}
+		m.Answer = append(m.Answer, ipRecords(q.Name, q.Qtype, []net.IP{ip})...)
+	case (len(labels) == 2 || len(labels) == 3) && labels[len(labels)-1] == "service":
+		var tag string
+		if len(labels) == 3 {
+			tag = labels[0]
+		}
+		instances := s.dnsInstances(labels[len(labels)-2], tag)
+		if len(instances) == 0 {
+			m.Rcode = dns.RcodeNameError
+			return
+		}
+		for _, instance := range instances {
+			switch q.Qtype {
+			case dns.TypeSRV:
+				target, extra := srvTarget(instance.Host)
+				m.Answer = append(m.Answer, &dns.SRV{
+					Hdr:      dns.RR_Header{Name: q.Name, Rrtype: dns.TypeSRV, Class: dns.ClassINET},
+					Priority: 1,
+					Weight:   1,
+					Port:     uint16(instance.Port),
+					Target:   target,
+				})
+				m.Extra = append(m.Extra, extra...)
+			default:
+				m.Answer = append(m.Answer, ipRecords(q.Name, q.Qtype, lookupHost(instance.Host))...)
+			}
+		}
+	default:
+		m.Rcode = dns.RcodeNameError
+	}
+}
+
+// dnsInstances are the healthy services of the name with the tag, readable by the DNS token.
+// The names match case-insensitively, so the ACLs are checked with the name of each instance.
+func (s *server) dnsInstances(name string, tag string) []*ServiceSpec {
+	var policy *Policy
+	if s.acl != nil {
+		policy = s.acl.policy(s.dnsToken)
+		if policy == nil {
+			return nil
+		}
+	}
+
+	var instances []*ServiceSpec
+	for _, spec := range s.storage.Services() {
+		if !strings.EqualFold(spec.Name, name) || !spec.healthy() {
+			continue
+		}
+		if policy != nil && !policy.allows(AccessRead, spec.Name) {
+			continue
+		}
+		if tag != "" && !hasTag(spec.Tags, tag) {
+			continue
+		}
+		instances = append(instances, spec)
+	}
+	return instances
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupHost returns the IP of the host, the hostnames are resolved by the system
+func lookupHost(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	return ips
+}
+
+// srvTarget is the hostname itself or the addr name of the IP with its record
+func srvTarget(host string) (string, []dns.RR) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return dns.Fqdn(host), nil
+	}
+	target := encodeAddr(ip) + ".addr." + DNSDomain
+	return target, append(ipRecords(target, dns.TypeA, []net.IP{ip}), ipRecords(target, dns.TypeAAAA, []net.IP{ip})...)
+}
+
+// ipRecords are the A or AAAA records of the IPs matching the qtype
+func ipRecords(name string, qtype uint16, ips []net.IP) []dns.RR {
+	var records []dns.RR
+	for _, ip := range ips {
+		ip4 := ip.To4()
+		switch {
+		case qtype == dns.TypeA && ip4 != nil:
+			records = append(records, &dns.A{
+				Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+				A:   ip4,
+			})
+		case qtype == dns.TypeAAAA && ip4 == nil:
+			records = append(records, &dns.AAAA{
+				Hdr:  dns.RR_Header{Name: name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET},
+				AAAA: ip,
+			})
+		}
+	}
+	return records
+}
+
+// encodeAddr is the hex of the IP, 8 digits for IPv4 and 32 for IPv6 like Consul
+func encodeAddr(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return hex.EncodeToString(ip4)
+	}
+	return hex.EncodeToString(ip.To16())
+}
+
+func decodeAddr(label string) net.IP {
+	b, err := hex.DecodeString(label)
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return nil
+	}
+	return net.IP(b)
+}
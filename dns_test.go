@@ -0,0 +1,118 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNS(t *testing.T) {
+	s := newTestServer(t, WithDNS("127.0.0.1:0"))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	addr := s.DNSAddr().String()
+
+	var storage = s.storage
+	webID, err := storage.Register("web", "127.0.0.1", 8080, []string{"primary"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Register("web", "::1", 8081, []string{"secondary"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Register("db", "127.0.0.2", 5432, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the services without healthcheck are healthy for the DNS and the Healthy filter alike
+	var servicesResp ServicesResponse
+	if err := s.services(&ServicesRequest{Healthy: true}, &servicesResp); err != nil {
+		t.Fatal(err)
+	}
+	if servicesResp.Total != 3 {
+		t.Errorf("The services without healthcheck should be healthy, instead of %v", servicesResp.Services)
+	}
+
+	var query = func(network string, name string, qtype uint16) *dns.Msg {
+		var m = new(dns.Msg)
+		m.SetQuestion(name, qtype)
+		client := &dns.Client{Net: network}
+		resp, _, err := client.Exchange(m, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := query("udp", "web.service.catalog.", dns.TypeA)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "127.0.0.1" {
+		t.Errorf("The A record should be 127.0.0.1, instead of %v", resp.Answer)
+	}
+	resp = query("tcp", "web.service.catalog.", dns.TypeAAAA)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.AAAA).AAAA.String() != "::1" {
+		t.Errorf("The AAAA record should be ::1, instead of %v", resp.Answer)
+	}
+
+	resp = query("udp", "secondary.web.service.catalog.", dns.TypeSRV)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.SRV).Port != 8081 {
+		t.Fatalf("The SRV record should be on port 8081, instead of %v", resp.Answer)
+	}
+	target := resp.Answer[0].(*dns.SRV).Target
+	resp = query("udp", target, dns.TypeAAAA)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.AAAA).AAAA.String() != "::1" {
+		t.Errorf("The target %s should be ::1, instead of %v", target, resp.Answer)
+	}
+
+	// the services in maintenance and the unknown ones aren't answered
+	if err := storage.EnableMaintenance(webID, "upgrade"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"primary.web.service.catalog.", "cache.service.catalog."} {
+		resp = query("udp", name, dns.TypeA)
+		if resp.Rcode != dns.RcodeNameError {
+			t.Errorf("%s should be NXDOMAIN, instead of %s", name, dns.RcodeToString[resp.Rcode])
+		}
+	}
+}
+
+func TestDNSACL(t *testing.T) {
+	s := newTestServer(t, WithDNS("127.0.0.1:0"), WithACL("master"), WithDNSToken("dns"))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	addr := s.DNSAddr().String()
+
+	if _, err := s.acl.set(Token{SecretID: "dns", Policy: Policy{Rules: []Rule{
+		{Prefix: "", Access: AccessRead},
+		{Prefix: "Secret", Access: AccessDeny},
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.storage.Register("Secret-db", "127.0.0.1", 5432, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the query names are case-insensitive, the denied service can't be read in lower case
+	var m = new(dns.Msg)
+	m.SetQuestion("secret-db.service.catalog.", dns.TypeA)
+	resp, _, err := new(dns.Client).Exchange(m, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Rcode != dns.RcodeNameError || len(resp.Answer) != 0 {
+		t.Errorf("The denied service should be NXDOMAIN, instead of %s %v", dns.RcodeToString[resp.Rcode], resp.Answer)
+	}
+
+	// the readable instances of the same name are still answered
+	if _, err := s.storage.Register("secret-db", "127.0.0.2", 5432, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	resp, _, err = new(dns.Client).Exchange(m, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "127.0.0.2" {
+		t.Errorf("Only the readable instance should be answered, instead of %v", resp.Answer)
+	}
+}
@@ -1,8 +1,44 @@
 package catalog
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 var (
 	ErrUndefinedService      = errors.New("undefined service")
 	ErrServiceRequestInvalid = errors.New("service request must contain at least an ID")
+	ErrInvalidSortKey        = errors.New("invalid sort key")
+	ErrInvalidPagination     = errors.New("limit and offset must not be negative")
+	ErrUnknownCheckType      = errors.New("unknown check type")
+	ErrInvalidCheck          = errors.New("check definition missing required field")
+	ErrUndefinedCheck        = errors.New("undefined check")
+	ErrDuplicateCheck        = errors.New("duplicate check name")
+	ErrUnknownHealthPolicy   = errors.New("unknown health policy")
+	ErrInvalidStatus         = errors.New("invalid health status")
+	ErrInvalidFault          = errors.New("fault latency must not be negative and rates must be between 0 and 1")
+	ErrInjectedFault         = errors.New("injected fault")
+	ErrInvalidDependency     = errors.New("dependency must have a name")
+	ErrDependencyCycle       = errors.New("dependency cycle")
+	ErrUnsupportedVersion    = errors.New("unsupported protocol version")
+	ErrPermissionDenied      = errors.New("permission denied")
+	ErrInvalidPolicy         = errors.New("rule access must be deny, read or write")
+	ErrUndefinedToken        = errors.New("undefined token")
+	ErrACLDisabled           = errors.New("acl disabled")
+	ErrExecChecksDisabled    = errors.New("exec checks disabled")
+	ErrInvalidRequest        = errors.New("invalid request")
+
+	ErrInvalidDeregisterCriticalAfter = errors.New("deregister critical after must not be negative")
+	ErrInvalidOverrideDuration        = errors.New("override duration must not be negative")
 )
+
+// HealthcheckErrors collects the failed healthchecks of a healthcheck round
+type HealthcheckErrors []error
+
+func (e HealthcheckErrors) Error() string {
+	var msgs = make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return "healthcheck failed: " + strings.Join(msgs, "; ")
+}
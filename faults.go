@@ -0,0 +1,259 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// errCloseConnection makes Listen close the connection after writing the response,
+// used by the dropped and truncated faults
+var errCloseConnection = errors.New("connection closed by injected fault")
+
+// Fault is a fault injected into the request path of the server, so the retry and
+// timeout logic of the clients can be tested against the catalog itself.
+// The rates are probabilities between 0 and 1.
+type Fault struct {
+	// Commands the fault applies to, empty means all of the commands
+	Commands Commands `json:"commands"`
+	// Service is the name of the service the fault applies to, empty means all of the requests
+	Service string `json:"service"`
+
+	// Latency is added before the response
+	Latency time.Duration `json:"latency"`
+	// ErrorRate of the requests answered with ErrInjectedFault
+	ErrorRate float64 `json:"error_rate"`
+	// DropRate of the connections closed without response
+	DropRate float64 `json:"drop_rate"`
+	// TruncateRate of the responses cut in half without delimiter
+	TruncateRate float64 `json:"truncate_rate"`
+	// StaleRate of the requests answered with the previous response of the same request
+	StaleRate float64 `json:"stale_rate"`
+}
+
+// Commands is a list of commands encoded as a JSON array of numbers,
+// a plain []Command would be encoded as base64 like a []byte
+type Commands []Command
+
+// MarshalJSON encodes the commands as numbers
+func (c Commands) MarshalJSON() ([]byte, error) {
+	if c == nil {
+		return []byte("null"), nil
+	}
+	var numbers = make([]int, len(c))
+	for i, cmd := range c {
+		numbers[i] = int(cmd)
+	}
+	return json.Marshal(numbers)
+}
+
+// UnmarshalJSON decodes the commands from an array of numbers
+func (c *Commands) UnmarshalJSON(data []byte) error {
+	var numbers []int
+	err := json.Unmarshal(data, &numbers)
+	if err != nil {
+		return err
+	}
+	if numbers == nil {
+		*c = nil
+		return nil
+	}
+	*c = make(Commands, len(numbers))
+	for i, number := range numbers {
+		if number < 0 || number > math.MaxUint8 {
+			return ErrInvalidFault
+		}
+		(*c)[i] = Command(number)
+	}
+	return nil
+}
+
+// Validate checks the latency and the rates of the fault
+func (f *Fault) Validate() error {
+	if f.Latency < 0 {
+		return ErrInvalidFault
+	}
+	for _, rate := range []float64{f.ErrorRate, f.DropRate, f.TruncateRate, f.StaleRate} {
+		if rate < 0 || rate > 1 {
+			return ErrInvalidFault
+		}
+	}
+	return nil
+}
+
+func (f *Fault) matches(cmd Command, service string) bool {
+	if f.Service != "" && f.Service != service {
+		return false
+	}
+	if len(f.Commands) == 0 {
+		return true
+	}
+	for _, c := range f.Commands {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// injection is the outcome of the matching faults for a request
+type injection struct {
+	latency  time.Duration
+	fail     bool
+	drop     bool
+	truncate bool
+	stale    bool
+	// cache the response for the later stale responses
+	cache bool
+}
+
+// staleKey identifies the request answered by a stale response, the token keeps
+// the responses filtered by the ACLs of one token from the others
+type staleKey struct {
+	cmd     Command
+	service string
+	version int
+	codec   string
+	token   string
+}
+
+// faultInjector holds the faults switched on at runtime
+type faultInjector struct {
+	mutex  sync.Mutex
+	faults []Fault
+	stale  map[staleKey][]byte
+	rand   *rand.Rand
+}
+
+func newFaultInjector() *faultInjector {
+	return &faultInjector{
+		stale: make(map[staleKey][]byte),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// set replaces the faults, empty faults switch the injection off
+func (fi *faultInjector) set(faults []Fault) error {
+	for i := range faults {
+		if err := faults[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.faults = faults
+	fi.stale = make(map[staleKey][]byte)
+	return nil
+}
+
+func (fi *faultInjector) list() []Fault {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	return append([]Fault{}, fi.faults...)
+}
+
+// inject rolls the matching faults of the request, the latencies add up
+func (fi *faultInjector) inject(cmd Command, service string) injection {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+
+	var inj injection
+	for i := range fi.faults {
+		f := &fi.faults[i]
+		if !f.matches(cmd, service) {
+			continue
+		}
+		inj.latency += f.Latency
+		inj.fail = inj.fail || fi.roll(f.ErrorRate)
+		inj.drop = inj.drop || fi.roll(f.DropRate)
+		inj.truncate = inj.truncate || fi.roll(f.TruncateRate)
+		inj.stale = inj.stale || fi.roll(f.StaleRate)
+		inj.cache = inj.cache || f.StaleRate > 0
+	}
+	return inj
+}
+
+func (fi *faultInjector) roll(rate float64) bool {
+	return rate > 0 && fi.rand.Float64() < rate
+}
+
+func (fi *faultInjector) cached(key staleKey) ([]byte, bool) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	resp, ok := fi.stale[key]
+	return resp, ok
+}
+
+func (fi *faultInjector) cache(key staleKey, resp []byte) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.stale[key] = resp
+}
+
+// requestService returns the name of the service of the request, the services
+// identified by ID are looked up in the storage
+func requestService(storage Storage, req *Request) string {
+	var target struct {
+		ID   *Identifier `json:"id"`
+		Name *string     `json:"name"`
+	}
+	if err := req.decodePayload(&target); err != nil {
+		return ""
+	}
+	if target.Name != nil {
+		return *target.Name
+	}
+	if target.ID != nil {
+		if ss, err := storage.Service(target.ID, nil); err == nil {
+			return ss.Name
+		}
+	}
+	return ""
+}
+
+// handleWithFaults handles the request with the faults matching its command and service
+func (s *server) handleWithFaults(req *Request) ([]byte, error) {
+	service := requestService(s.storage, req)
+	key := staleKey{cmd: req.Cmd, service: service, version: req.Version, codec: req.codecOf().Name(), token: req.Token}
+	inj := s.faults.inject(req.Cmd, service)
+
+	if inj.latency > 0 {
+		time.Sleep(inj.latency)
+	}
+	switch {
+	case inj.drop:
+		return nil, errCloseConnection
+	case inj.fail:
+		respJSON, err := req.encodePayload(FaultsResponse{Success: false, Error: ErrInjectedFault.Error()})
+		if err != nil {
+			return nil, err
+		}
+		return req.encodeResponse(req.response(respJSON))
+	case inj.stale:
+		if cached, ok := s.faults.cached(key); ok {
+			// the stale response answers the current request
+			var resp Response
+			if err := req.codecOf().Unmarshal(unframe(req.codecOf(), cached), &resp); err != nil {
+				return nil, err
+			}
+			resp.ID = req.ID
+			return req.encodeResponse(resp)
+		}
+	}
+
+	resp, err := s.handle(req)
+	if err != nil {
+		return nil, err
+	}
+	if inj.cache {
+		s.faults.cache(key, resp)
+	}
+	if inj.truncate {
+		return resp[:len(resp)/2], errCloseConnection
+	}
+	return resp, nil
+}
@@ -0,0 +1,153 @@
+package catalog
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func faultReq(t *testing.T, s *server, cmd Command, req interface{}) ([]byte, error) {
+	t.Helper()
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rJSON, err := json.Marshal(Request{Cmd: cmd, Req: string(reqJSON)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.handleRequest(rJSON)
+}
+
+func TestFaults(t *testing.T) {
+	s := newTestServer(t)
+
+	flaky, other := "flaky", "other"
+	for _, name := range []string{flaky, other} {
+		if _, err := s.storage.Register(name, localhost, 9008, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := faultReq(t, s, Faults, FaultsRequest{Faults: []Fault{{ErrorRate: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var faultsResp FaultsResponse
+	unmarshalResp(t, resp, &faultsResp)
+	if faultsResp.Success || faultsResp.Error != ErrInvalidFault.Error() {
+		t.Errorf("Invalid fault should be rejected, instead of %v %s", faultsResp.Success, faultsResp.Error)
+	}
+
+	var faults = []Fault{
+		{Commands: []Command{Service}, Service: flaky, ErrorRate: 1, Latency: 20 * time.Millisecond},
+		{Commands: []Command{Deregister}, DropRate: 1},
+		{Commands: []Command{HealthHistory}, TruncateRate: 1},
+		{Commands: []Command{Services}, StaleRate: 1},
+	}
+	if _, err := faultReq(t, s, Faults, FaultsRequest{Faults: faults}); err != nil {
+		t.Fatal(err)
+	}
+
+	// error response with latency, scoped to the service
+	start := time.Now()
+	resp, err = faultReq(t, s, Service, ServiceRequest{Name: &flaky})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("Latency should be injected")
+	}
+	var serviceResp ServiceResponse
+	unmarshalResp(t, resp, &serviceResp)
+	if serviceResp.Success || serviceResp.Error != ErrInjectedFault.Error() {
+		t.Errorf("Service request should fail, instead of %v %s", serviceResp.Success, serviceResp.Error)
+	}
+	resp, err = faultReq(t, s, Service, ServiceRequest{Name: &other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmarshalResp(t, resp, &serviceResp)
+	if !serviceResp.Success {
+		t.Errorf("Other service shouldn't be faulted, instead of %s", serviceResp.Error)
+	}
+
+	// dropped connection
+	resp, err = faultReq(t, s, Deregister, DeregisterRequest{Name: &other})
+	if err != errCloseConnection || len(resp) != 0 {
+		t.Errorf("Connection should be dropped, instead of %v %s", err, resp)
+	}
+
+	// truncated line
+	resp, err = faultReq(t, s, HealthHistory, HealthHistoryRequest{Name: &other})
+	if err != errCloseConnection || len(resp) == 0 || resp[len(resp)-1] == delimiterByte {
+		t.Errorf("Response should be truncated, instead of %v %s", err, resp)
+	}
+
+	// stale response
+	first, err := faultReq(t, s, Services, ServicesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.storage.Register("new", localhost, 9009, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	second, err := faultReq(t, s, Services, ServicesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != string(second) {
+		t.Error("Response should be stale")
+	}
+
+	// switched off
+	if _, err := faultReq(t, s, Faults, FaultsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = faultReq(t, s, Service, ServiceRequest{Name: &flaky})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmarshalResp(t, resp, &serviceResp)
+	if !serviceResp.Success {
+		t.Errorf("Faults should be switched off, instead of %s", serviceResp.Error)
+	}
+}
+
+func TestFaultCommandsJSON(t *testing.T) {
+	faultJSON, err := json.Marshal(Fault{Commands: []Command{Register, Service}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(faultJSON, &raw); err != nil {
+		t.Fatal(err)
+	}
+	commands, ok := raw["commands"].([]interface{})
+	if !ok || len(commands) != 2 || commands[0] != float64(Register) || commands[1] != float64(Service) {
+		t.Errorf("Commands should be an array of numbers, instead of %s", faultJSON)
+	}
+
+	// the clients send the commands as numbers
+	var fault Fault
+	if err := json.Unmarshal([]byte(`{"commands":[0,3]}`), &fault); err != nil {
+		t.Fatal(err)
+	}
+	if len(fault.Commands) != 2 || fault.Commands[0] != Register || fault.Commands[1] != Service {
+		t.Errorf("Commands should be decoded, instead of %v", fault.Commands)
+	}
+	if err := json.Unmarshal([]byte(`{"commands":[256]}`), &fault); err != ErrInvalidFault {
+		t.Errorf("Error should be %v, instead of %v", ErrInvalidFault, err)
+	}
+}
+
+func unmarshalResp(t *testing.T, message []byte, v interface{}) {
+	t.Helper()
+	var resp Response
+	if err := json.Unmarshal(message, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(resp.Resp), v); err != nil {
+		t.Fatal(err)
+	}
+}
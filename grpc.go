@@ -0,0 +1,365 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/PumpkinSeed/catalog/api/catalogpb"
+)
+
+// GRPCTokenKey is the gRPC metadata key of the ACL token
+const GRPCTokenKey = "x-catalog-token"
+
+// watchBuffer is the number of events a Watch stream can fall behind
+const watchBuffer = 256
+
+// WithGRPC serves the gRPC API of the catalog on the TCP addr besides the other
+// listeners, over TLS if it is configured
+func WithGRPC(addr string) ServerOption {
+	return func(s *server) {
+		s.grpcAddr = addr
+	}
+}
+
+// listenGRPC opens the listener of the gRPC API, nil if it isn't configured
+func (s *server) listenGRPC() (net.Listener, *grpc.Server, error) {
+	if s.grpcAddr == "" {
+		return nil, nil, nil
+	}
+	ln, err := net.Listen("tcp", s.grpcAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var options []grpc.ServerOption
+	if s.tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+	gs := grpc.NewServer(options...)
+	catalogpb.RegisterCatalogServer(gs, &grpcCatalog{s: s})
+	return ln, gs, nil
+}
+
+// GRPCAddr is the address of the gRPC listener, the port chosen for :0 included
+func (s *server) GRPCAddr() net.Addr {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.grpcListener == nil {
+		return nil
+	}
+	return s.grpcListener.Addr()
+}
+
+// grpcCatalog serves the gRPC API with the handlers of the socket protocol
+type grpcCatalog struct {
+	catalogpb.UnimplementedCatalogServer
+	s *server
+}
+
+func (g *grpcCatalog) Register(ctx context.Context, in *catalogpb.RegisterRequest) (*catalogpb.RegisterResponse, error) {
+	var registerReq = registerRequestFromProto(in)
+	err := g.authorize(ctx, Register, registerReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var registerResp RegisterResponse
+	err = g.s.register(&registerReq, &registerResp)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !registerResp.Success {
+		return nil, grpcError(registerResp.Error)
+	}
+
+	var out = &catalogpb.RegisterResponse{Id: uint64(registerResp.ID)}
+	if len(registerResp.CheckFailures) > 0 {
+		out.CheckFailures = make(map[string]*catalogpb.CheckResult, len(registerResp.CheckFailures))
+		for name, result := range registerResp.CheckFailures {
+			out.CheckFailures[name] = checkResultToProto(result)
+		}
+	}
+	return out, nil
+}
+
+func (g *grpcCatalog) Deregister(ctx context.Context, in *catalogpb.DeregisterRequest) (*catalogpb.DeregisterResponse, error) {
+	var deregisterReq DeregisterRequest
+	deregisterReq.ID, deregisterReq.Name = selectorFromProto(in.GetService())
+	err := g.authorize(ctx, Deregister, deregisterReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var deregisterResp DeregisterResponse
+	err = g.s.deregister(&deregisterReq, &deregisterResp)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !deregisterResp.Success {
+		return nil, grpcError(deregisterResp.Error)
+	}
+	return &catalogpb.DeregisterResponse{}, nil
+}
+
+func (g *grpcCatalog) Service(ctx context.Context, in *catalogpb.ServiceRequest) (*catalogpb.ServiceResponse, error) {
+	var serviceReq ServiceRequest
+	serviceReq.ID, serviceReq.Name = selectorFromProto(in.GetService())
+	err := g.authorize(ctx, Service, serviceReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var serviceResp ServiceResponse
+	err = g.s.service(&serviceReq, &serviceResp)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !serviceResp.Success {
+		return nil, grpcError(serviceResp.Error)
+	}
+	return &catalogpb.ServiceResponse{Service: serviceSpecToProto(&serviceResp.Service)}, nil
+}
+
+func (g *grpcCatalog) Services(ctx context.Context, in *catalogpb.ServicesRequest) (*catalogpb.ServicesResponse, error) {
+	var servicesReq = ServicesRequest{
+		SortBy:  in.GetSortBy(),
+		Limit:   int(in.GetLimit()),
+		Offset:  int(in.GetOffset()),
+		Healthy: in.GetHealthy(),
+	}
+	req, err := g.request(ctx, Services, servicesReq)
+	if err != nil {
+		return nil, err
+	}
+	servicesReq.visible = req.readable
+
+	var servicesResp ServicesResponse
+	err = g.s.services(&servicesReq, &servicesResp)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !servicesResp.Success {
+		return nil, grpcError(servicesResp.Error)
+	}
+
+	var out = &catalogpb.ServicesResponse{Total: int32(servicesResp.Total)}
+	for i := range servicesResp.Services {
+		out.Services = append(out.Services, serviceSpecToProto(&servicesResp.Services[i]))
+	}
+	return out, nil
+}
+
+func (g *grpcCatalog) Watch(in *catalogpb.WatchRequest, stream catalogpb.Catalog_WatchServer) error {
+	req, err := g.request(stream.Context(), Services, ServicesRequest{})
+	if err != nil {
+		return err
+	}
+
+	// the events are dropped by the storage goroutine when the stream falls behind
+	var events = make(chan Event, watchBuffer)
+	var overflow = make(chan struct{})
+	var overflowed bool
+	stop := g.s.storage.Watch(func(event Event) {
+		if (in.GetName() != "" && event.Service.Name != in.GetName()) || !req.readable(event.Service.Name) {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+			if !overflowed {
+				overflowed = true
+				close(overflow)
+			}
+		}
+	})
+	defer stop()
+	// the headers tell the client the events are watched from now on
+	err = stream.SendHeader(metadata.MD{})
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-overflow:
+			return status.Error(codes.ResourceExhausted, "watcher too slow")
+		case event := <-events:
+			err := stream.Send(&catalogpb.WatchEvent{
+				Kind:    eventKindToProto(event.Kind),
+				Service: serviceSpecToProto(&event.Service),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// request builds the Request of the socket protocol for the ACLs, the token is in the metadata
+func (g *grpcCatalog) request(ctx context.Context, cmd Command, payload interface{}) (*Request, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	var req = Request{Cmd: cmd, Version: ProtocolV2, Payload: payloadJSON}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if tokens := md.Get(GRPCTokenKey); len(tokens) > 0 {
+			req.Token = tokens[0]
+		}
+	}
+
+	err = g.s.authorize(&req)
+	if err == ErrPermissionDenied {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &req, nil
+}
+
+func (g *grpcCatalog) authorize(ctx context.Context, cmd Command, payload interface{}) error {
+	_, err := g.request(ctx, cmd, payload)
+	return err
+}
+
+// grpcError maps the error of a response to a gRPC status
+func grpcError(msg string) error {
+	switch msg {
+	case ErrUndefinedService.Error():
+		return status.Error(codes.NotFound, msg)
+	case ErrPermissionDenied.Error():
+		return status.Error(codes.PermissionDenied, msg)
+	}
+	return status.Error(codes.InvalidArgument, msg)
+}
+
+func selectorFromProto(selector interface{}) (*Identifier, *string) {
+	switch selector := selector.(type) {
+	case *catalogpb.DeregisterRequest_Id:
+		id := Identifier(selector.Id)
+		return &id, nil
+	case *catalogpb.DeregisterRequest_Name:
+		return nil, &selector.Name
+	case *catalogpb.ServiceRequest_Id:
+		id := Identifier(selector.Id)
+		return &id, nil
+	case *catalogpb.ServiceRequest_Name:
+		return nil, &selector.Name
+	}
+	return nil, nil
+}
+
+func registerRequestFromProto(in *catalogpb.RegisterRequest) RegisterRequest {
+	var rr = RegisterRequest{
+		Name:    in.GetName(),
+		Address: in.GetAddress(),
+		Port:    int(in.GetPort()),
+		Tags:    in.GetTags(),
+		HealthPolicy: HealthPolicy{
+			Mode:      in.GetHealthPolicy().GetMode(),
+			Threshold: in.GetHealthPolicy().GetThreshold(),
+		},
+		DeregisterCriticalAfter: durationFromProto(in.GetDeregisterCriticalAfter()),
+	}
+	if in.GetAdditional() != nil {
+		rr.Additional = in.GetAdditional().AsInterface()
+	}
+	for _, check := range in.GetChecks() {
+		rr.Checks = append(rr.Checks, CheckDefinition{
+			Name:        check.GetName(),
+			Type:        check.GetType(),
+			Weight:      int(check.GetWeight()),
+			Address:     check.GetAddress(),
+			URL:         check.GetUrl(),
+			Status:      int(check.GetStatus()),
+			Body:        check.GetBody(),
+			GRPCService: check.GetGrpcService(),
+			Command:     check.GetCommand(),
+			ExitCode:    int(check.GetExitCode()),
+			Interval:    durationFromProto(check.GetInterval()),
+			Timeout:     durationFromProto(check.GetTimeout()),
+		})
+	}
+	for _, dependency := range in.GetDependencies() {
+		rr.Dependencies = append(rr.Dependencies, Dependency{Name: dependency.GetName(), Required: dependency.GetRequired()})
+	}
+	return rr
+}
+
+func durationFromProto(d *durationpb.Duration) time.Duration {
+	if d == nil {
+		return 0
+	}
+	return d.AsDuration()
+}
+
+func timestampToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func checkResultToProto(result *CheckResult) *catalogpb.CheckResult {
+	return &catalogpb.CheckResult{
+		Status:      string(result.Status),
+		Output:      result.Output,
+		LastChecked: timestampToProto(result.LastChecked),
+		LastChange:  timestampToProto(result.LastChange),
+	}
+}
+
+func serviceSpecToProto(spec *ServiceSpec) *catalogpb.ServiceSpec {
+	var out = &catalogpb.ServiceSpec{
+		Id:               uint64(spec.ID),
+		Name:             spec.Name,
+		Host:             spec.Host,
+		Port:             int32(spec.Port),
+		Address:          spec.Address,
+		Tags:             spec.Tags,
+		RegisteredAt:     timestampToProto(spec.RegisteredAt),
+		IsAlive:          spec.IsAlive,
+		Status:           string(spec.Status),
+		DependencyStatus: string(spec.DependencyStatus),
+	}
+	if len(spec.Checks) > 0 {
+		out.Checks = make(map[string]*catalogpb.CheckResult, len(spec.Checks))
+		for name, result := range spec.Checks {
+			out.Checks[name] = checkResultToProto(result)
+		}
+	}
+	if spec.Maintenance != nil {
+		out.Maintenance = &catalogpb.Maintenance{
+			Reason: spec.Maintenance.Reason,
+			Since:  timestampToProto(spec.Maintenance.Since),
+		}
+	}
+	return out
+}
+
+func eventKindToProto(kind EventKind) catalogpb.WatchEvent_Kind {
+	switch kind {
+	case EventRegister:
+		return catalogpb.WatchEvent_KIND_REGISTER
+	case EventDeregister:
+		return catalogpb.WatchEvent_KIND_DEREGISTER
+	case EventHealthChange:
+		return catalogpb.WatchEvent_KIND_HEALTH_CHANGE
+	case EventUpdate:
+		return catalogpb.WatchEvent_KIND_UPDATE
+	}
+	return catalogpb.WatchEvent_KIND_UNSPECIFIED
+}
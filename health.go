@@ -1,47 +1,165 @@
 package catalog
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"time"
 )
 
-// @TODO setup healthcheck chain
-func healthcheck(services map[Identifier]*ServiceSpec, mutex *sync.RWMutex) error {
-	var errChan = make(chan error)
-	var counter = 0
-	for _, service := range services {
-		counter++
-		go func(service *ServiceSpec) {
-			defer func() {
-				counter--
-			}()
-			if service.Healthcheck {
-				alive, err := service.HealthcheckFunc()
-				if err != nil {
-					errChan <- err
-					return
-				}
+// healthcheckWorkers is the maximum number of healthchecks running at the same time
+const healthcheckWorkers = 16
 
-				mutex.Lock()
-				service.IsAlive = alive
-				mutex.Unlock()
-			}
-			return
-		}(service)
+// CheckFunc is a context-aware healthcheck, it should return when the context
+// done. Empty status means passing without error and critical with error,
+// the error is the output of the check.
+type CheckFunc func(ctx context.Context) (Status, error)
+
+// AdaptCheck wraps a healthcheck closure into a CheckFunc, true with
+// an error is a warning. The closure can't be cancelled, but its
+// result is dropped after the timeout of the check.
+func AdaptCheck(f func() (bool, error)) CheckFunc {
+	return func(ctx context.Context) (Status, error) {
+		alive, err := f()
+		return statusOf(alive, err), err
 	}
+}
 
+// checkOutput is the output of a passing check, the CheckFunc returns it as the error
+// with StatusPassing, so it is recorded like the output of the failing checks
+type checkOutput string
+
+func (o checkOutput) Error() string {
+	return string(o)
+}
+
+// runCheck runs the check with its timeout and measures its latency,
+// a timed out or panicking check is critical
+func runCheck(ctx context.Context, check *Check) (Status, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, check.timeout())
+	defer cancel()
+
+	type result struct {
+		status Status
+		err    error
+	}
+	var done = make(chan result, 1)
+	start := time.Now()
 	go func() {
-		for {
-			if counter == 0 {
-				errChan <- nil
-				break
+		// a misbehaving check fails only itself
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{status: StatusCritical, err: fmt.Errorf("check panicked: %v", r)}
 			}
-		}
+		}()
+		status, err := check.Func(ctx)
+		done <- result{status: status, err: err}
 	}()
 
-	for {
+	select {
+	case r := <-done:
+		if r.status == "" && r.err != nil {
+			r.status = StatusCritical
+		} else if r.status == "" {
+			r.status = StatusPassing
+		}
+		return r.status, time.Since(start), r.err
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			err = fmt.Errorf("check timed out after %v", check.timeout())
+		}
+		return StatusCritical, time.Since(start), err
+	}
+}
+
+// healthcheckJob is a check of a service, run by a healthcheck round or by the scheduler
+type healthcheckJob struct {
+	id      Identifier
+	name    string
+	service *ServiceSpec
+	check   *Check
+}
+
+// run runs the check and records its result unless the service or the check was removed
+// during the check, onResult is called with the locked mutex. The error of a failed check
+// is returned.
+func (job healthcheckJob) run(ctx context.Context, services map[Identifier]*ServiceSpec, mutex *sync.RWMutex, onResult func(service *ServiceSpec, changed bool)) error {
+	status, latency, err := runCheck(ctx, job.check)
+
+	mutex.Lock()
+	if services[job.id] == job.service && job.service.healthchecks[job.check.Name] == job.check {
+		changed := job.service.setResult(job.check.Name, status, err, time.Now(), latency)
+		if onResult != nil {
+			onResult(job.service, changed)
+		}
+	}
+	mutex.Unlock()
+
+	// the output of a passing check isn't a failure
+	if err == nil || status == StatusPassing {
+		return nil
+	}
+	return fmt.Errorf("%s (%d) %s: %w", job.name, job.id, job.check.Name, err)
+}
+
+// healthcheck runs the healthchecks of the services on a bounded number of workers,
+// onResult called with the locked mutex after every result, changed reports whether the status of the service changed.
+// The errors of the checks are recorded as the output of the checks and collected
+// into HealthcheckErrors, the remaining checks are skipped when the context cancelled.
+func healthcheck(ctx context.Context, services map[Identifier]*ServiceSpec, mutex *sync.RWMutex, workers int, onResult func(service *ServiceSpec, changed bool)) error {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	// snapshot the checks, so the services can change during the round
+	mutex.RLock()
+	var jobs = make([]healthcheckJob, 0, len(services))
+	for id, service := range services {
+		for _, check := range service.healthchecks {
+			jobs = append(jobs, healthcheckJob{id: id, name: service.Name, service: service, check: check})
+		}
+	}
+	mutex.RUnlock()
+
+	var jobCh = make(chan healthcheckJob)
+	var errs HealthcheckErrors
+	var errMutex sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(jobs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobCh {
+				err := job.run(ctx, services, mutex, onResult)
+				if err != nil {
+					errMutex.Lock()
+					errs = append(errs, err)
+					errMutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	var cancelled error
+	for _, job := range jobs {
 		select {
-		case err := <-errChan:
-			return err
+		case jobCh <- job:
+		case <-ctx.Done():
+			cancelled = ctx.Err()
 		}
+		if cancelled != nil {
+			break
+		}
+	}
+	close(jobCh)
+	wg.Wait()
+
+	if cancelled != nil {
+		return cancelled
+	}
+	if len(errs) > 0 {
+		return errs
 	}
+	return nil
 }
@@ -1,6 +1,8 @@
 package catalog
 
 import (
+	"context"
+	"errors"
 	"net"
 	"sync"
 	"testing"
@@ -48,40 +50,40 @@ var services = []struct {
 
 var serviceSpecs = map[Identifier]*ServiceSpec{
 	services[0].id: {
-		ID:              services[0].id,
-		Name:            "webserver",
-		Host:            localhost,
-		Port:            8091,
-		Healthcheck:     true,
-		HealthcheckFunc: getCommonHCFunc(localhost + ":8091"),
-		IsAlive:         true,
+		ID:           services[0].id,
+		Name:         "webserver",
+		Host:         localhost,
+		Port:         8091,
+		Healthcheck:  true,
+		healthchecks: defaultCheck(getCommonHCFunc(localhost + ":8091")),
+		IsAlive:      true,
 	},
 	services[1].id: {
-		ID:              services[1].id,
-		Name:            "authservice",
-		Host:            localhost,
-		Port:            7000,
-		Healthcheck:     true,
-		HealthcheckFunc: getCommonHCFunc(localhost + ":7000"),
-		IsAlive:         true,
+		ID:           services[1].id,
+		Name:         "authservice",
+		Host:         localhost,
+		Port:         7000,
+		Healthcheck:  true,
+		healthchecks: defaultCheck(getCommonHCFunc(localhost + ":7000")),
+		IsAlive:      true,
 	},
 	services[2].id: {
-		ID:              services[2].id,
-		Name:            "searchservice",
-		Host:            localhost,
-		Port:            8088,
-		Healthcheck:     true,
-		HealthcheckFunc: getCommonHCFunc(localhost + ":8088"),
-		IsAlive:         true,
+		ID:           services[2].id,
+		Name:         "searchservice",
+		Host:         localhost,
+		Port:         8088,
+		Healthcheck:  true,
+		healthchecks: defaultCheck(getCommonHCFunc(localhost + ":8088")),
+		IsAlive:      true,
 	},
 	services[3].id: {
-		ID:              services[3].id,
-		Name:            "mailservice",
-		Host:            localhost,
-		Port:            8001,
-		Healthcheck:     true,
-		HealthcheckFunc: getCommonHCFunc(localhost + ":8001"),
-		IsAlive:         true,
+		ID:           services[3].id,
+		Name:         "mailservice",
+		Host:         localhost,
+		Port:         8001,
+		Healthcheck:  true,
+		healthchecks: defaultCheck(getCommonHCFunc(localhost + ":8001")),
+		IsAlive:      true,
 	},
 }
 
@@ -89,7 +91,7 @@ func TestHealthcheck(t *testing.T) {
 	startServices(t)
 
 	mutex := sync.RWMutex{}
-	err := healthcheck(serviceSpecs, &mutex)
+	err := healthcheck(context.Background(), serviceSpecs, &mutex, healthcheckWorkers, nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -133,6 +135,12 @@ func startServices(t *testing.T) {
 	}
 }
 
+func defaultCheck(f func() (bool, error)) map[string]*Check {
+	return map[string]*Check{
+		DefaultCheckName: {Name: DefaultCheckName, Func: AdaptCheck(f)},
+	}
+}
+
 func getCommonHCFunc(address string) func() (bool, error) {
 	return func() (bool, error) {
 		conn, err := net.Dial("tcp", address)
@@ -149,3 +157,154 @@ func getCommonHCFunc(address string) func() (bool, error) {
 		return true, nil
 	}
 }
+
+func TestHealthcheckErrors(t *testing.T) {
+	var specs = make(map[Identifier]*ServiceSpec)
+	for i, fails := range []bool{true, false, true} {
+		fails := fails
+		id := Identifier(i + 1)
+		specs[id] = &ServiceSpec{
+			ID:          id,
+			Name:        "errorservice",
+			Healthcheck: true,
+			healthchecks: defaultCheck(func() (bool, error) {
+				if fails {
+					return false, errors.New("check failed")
+				}
+				return true, nil
+			}),
+		}
+	}
+
+	err := healthcheck(context.Background(), specs, &sync.RWMutex{}, 2, nil)
+	errs, ok := err.(HealthcheckErrors)
+	if !ok {
+		t.Fatalf("Error should be HealthcheckErrors, instead of %v", err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("There should be 2 errors, instead of %d", len(errs))
+	}
+	if specs[2].IsAlive != true {
+		t.Error("Passing service should be alive")
+	}
+}
+
+func TestHealthcheckCancel(t *testing.T) {
+	var specs = make(map[Identifier]*ServiceSpec)
+	var release = make(chan struct{})
+	for i := 0; i < 10; i++ {
+		id := Identifier(i + 1)
+		specs[id] = &ServiceSpec{
+			ID:          id,
+			Healthcheck: true,
+			healthchecks: defaultCheck(func() (bool, error) {
+				<-release
+				return true, nil
+			}),
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var done = make(chan error)
+	go func() {
+		done <- healthcheck(ctx, specs, &sync.RWMutex{}, 1, nil)
+	}()
+
+	cancel()
+	close(release)
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Error should be context.Canceled, instead of %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Cancelled healthcheck should return")
+	}
+}
+
+func TestHealthcheckChurn(t *testing.T) {
+	hcStorage := func(name string) (time.Duration, func() (bool, error)) {
+		return time.Millisecond, func() (bool, error) {
+			return true, nil
+		}
+	}
+	storage := NewStorage(hcStorage, 2000*time.Millisecond, &sync.RWMutex{})
+	defer storage.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				id, err := storage.Register("churn", localhost, 9000+i, nil, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				for _, service := range storage.Services() {
+					_ = service.IsAlive
+				}
+				err = storage.Deregister(&id, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+
+	var stop = make(chan struct{})
+	var rounds sync.WaitGroup
+	rounds.Add(1)
+	go func() {
+		defer rounds.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := storage.Healthcheck(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(stop)
+	rounds.Wait()
+}
+
+func TestCheckTimeout(t *testing.T) {
+	var release = make(chan struct{})
+	defer close(release)
+
+	var cases = []struct {
+		name  string
+		check Check
+	}{
+		{"context aware", Check{Name: "ctx", Timeout: 20 * time.Millisecond, Func: func(ctx context.Context) (Status, error) {
+			<-ctx.Done()
+			return StatusPassing, nil
+		}}},
+		{"adapted", Check{Name: "adapted", Timeout: 20 * time.Millisecond, Func: AdaptCheck(func() (bool, error) {
+			<-release
+			return true, nil
+		})}},
+	}
+
+	for _, c := range cases {
+		start := time.Now()
+		status, _, err := runCheck(context.Background(), &c.check)
+		if time.Since(start) > time.Second {
+			t.Errorf("%s: check should time out", c.name)
+		}
+		if status != StatusCritical {
+			t.Errorf("%s: status should be %s, instead of %s", c.name, StatusCritical, status)
+		}
+		if err == nil || err.Error() != "check timed out after 20ms" {
+			t.Errorf("%s: output should be the timeout, instead of %v", c.name, err)
+		}
+	}
+}
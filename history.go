@@ -0,0 +1,51 @@
+package catalog
+
+import "time"
+
+// healthHistorySize is the number of check results kept per service
+const healthHistorySize = 64
+
+// HealthRecord is the result of a single run of a check
+type HealthRecord struct {
+	Check     string        `json:"check"`
+	Timestamp time.Time     `json:"timestamp"`
+	Status    Status        `json:"status"`
+	Latency   time.Duration `json:"latency"`
+	Output    string        `json:"output"`
+}
+
+// history is a ring buffer of the latest check results of a service
+type history struct {
+	records []HealthRecord
+	next    int
+	full    bool
+}
+
+func newHistory(size int) *history {
+	return &history{records: make([]HealthRecord, size)}
+}
+
+func (h *history) add(record HealthRecord) {
+	h.records[h.next] = record
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the records of the check from the oldest to the latest,
+// empty check means all of the checks
+func (h *history) list(check string) []HealthRecord {
+	var ordered = h.records[:h.next]
+	if h.full {
+		ordered = append(append([]HealthRecord(nil), h.records[h.next:]...), h.records[:h.next]...)
+	}
+
+	var records = make([]HealthRecord, 0, len(ordered))
+	for _, record := range ordered {
+		if check == "" || record.Check == check {
+			records = append(records, record)
+		}
+	}
+	return records
+}
@@ -0,0 +1,78 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistoryRing(t *testing.T) {
+	h := newHistory(3)
+	if len(h.list("")) != 0 {
+		t.Error("New history should be empty")
+	}
+
+	for i, check := range []string{"tcp", "http", "tcp", "http", "tcp"} {
+		h.add(HealthRecord{Check: check, Latency: time.Duration(i)})
+	}
+
+	records := h.list("")
+	if len(records) != 3 {
+		t.Fatalf("History should keep 3 records, instead of %d", len(records))
+	}
+	for i, record := range records {
+		if record.Latency != time.Duration(i+2) {
+			t.Errorf("Record %d should be the run %d, instead of %d", i, i+2, record.Latency)
+		}
+	}
+
+	if len(h.list("tcp")) != 2 {
+		t.Errorf("History should keep 2 tcp records, instead of %d", len(h.list("tcp")))
+	}
+}
+
+func TestStorageHealthHistory(t *testing.T) {
+	var runs int
+	storage := NewStorage(nil, time.Hour, &sync.RWMutex{})
+	defer storage.Close()
+
+	id, err := storage.Register("flapping", localhost, 9005, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.AddCheck(id, Check{Name: "tcp", Func: AdaptCheck(func() (bool, error) {
+		runs++
+		if runs%2 == 0 {
+			return false, errors.New("connection refused")
+		}
+		return true, nil
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		err = storage.Healthcheck(context.Background())
+		if err != nil && runs%2 != 0 {
+			t.Error(err)
+		}
+	}
+
+	records, err := storage.HealthHistory(&id, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("History should contain 4 records, instead of %d", len(records))
+	}
+	var expected = []Status{StatusPassing, StatusCritical, StatusPassing, StatusCritical}
+	for i, record := range records {
+		if record.Status != expected[i] {
+			t.Errorf("Record %d should be %s, instead of %s", i, expected[i], record.Status)
+		}
+	}
+	if records[1].Output != "connection refused" {
+		t.Errorf("Output should be recorded, instead of %s", records[1].Output)
+	}
+}
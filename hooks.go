@@ -0,0 +1,142 @@
+package catalog
+
+import (
+	"log"
+	"sync"
+)
+
+// Hooks are in-process callbacks of the storage. They run after the change
+// committed, with a copy of the service, in the order of the changes.
+// A panicking hook is recovered and logged, it doesn't affect the storage.
+type Hooks struct {
+	OnRegister     func(service ServiceSpec)
+	OnDeregister   func(service ServiceSpec)
+	OnHealthChange func(service ServiceSpec)
+	OnUpdate       func(service ServiceSpec)
+}
+
+// EventKind is the kind of a storage Event, one per hook
+type EventKind string
+
+// Kinds of the storage events
+const (
+	EventRegister     EventKind = "register"
+	EventDeregister   EventKind = "deregister"
+	EventHealthChange EventKind = "health_change"
+	EventUpdate       EventKind = "update"
+)
+
+// Event is a change of the storage delivered to the watchers, the Service is a copy
+type Event struct {
+	Kind    EventKind
+	Service ServiceSpec
+}
+
+// hook returns the hook of the kind
+func (h Hooks) hook(kind EventKind) func(service ServiceSpec) {
+	switch kind {
+	case EventRegister:
+		return h.OnRegister
+	case EventDeregister:
+		return h.OnDeregister
+	case EventHealthChange:
+		return h.OnHealthChange
+	case EventUpdate:
+		return h.OnUpdate
+	}
+	return nil
+}
+
+type event struct {
+	hook     func(service ServiceSpec)
+	watchers []func(event Event)
+	Event
+}
+
+// dispatcher runs the hooks and the watchers on a single goroutine, so the
+// events of a service always arrive in the order they were emitted
+type dispatcher struct {
+	mutex       sync.Mutex
+	hooks       Hooks
+	watchers    map[int]func(event Event)
+	nextWatcher int
+	queue       []event
+	running     bool
+}
+
+func (d *dispatcher) setHooks(hooks Hooks) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.hooks = hooks
+}
+
+// watch adds the watcher of the events emitted from now on until stop is called
+func (d *dispatcher) watch(f func(event Event)) (stop func()) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.watchers == nil {
+		d.watchers = make(map[int]func(event Event))
+	}
+	id := d.nextWatcher
+	d.nextWatcher++
+	d.watchers[id] = f
+	return func() {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		delete(d.watchers, id)
+	}
+}
+
+// emit queues the hook of the kind and the current watchers, it should be called
+// while the storage is still locked to keep the order of the changes
+func (d *dispatcher) emit(kind EventKind, service *ServiceSpec) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	hook := d.hooks.hook(kind)
+	if hook == nil && len(d.watchers) == 0 {
+		return
+	}
+	var watchers = make([]func(event Event), 0, len(d.watchers))
+	for _, watcher := range d.watchers {
+		watchers = append(watchers, watcher)
+	}
+
+	d.queue = append(d.queue, event{hook: hook, watchers: watchers, Event: Event{Kind: kind, Service: service.copy()}})
+	if !d.running {
+		d.running = true
+		go d.run()
+	}
+}
+
+func (d *dispatcher) run() {
+	for {
+		d.mutex.Lock()
+		if len(d.queue) == 0 {
+			d.running = false
+			d.mutex.Unlock()
+			return
+		}
+		e := d.queue[0]
+		d.queue = d.queue[1:]
+		d.mutex.Unlock()
+
+		if e.hook != nil {
+			d.call(e.Service.Name, func() { e.hook(e.Service) })
+		}
+		for _, watcher := range e.watchers {
+			d.call(e.Service.Name, func() { watcher(e.Event) })
+		}
+	}
+}
+
+func (d *dispatcher) call(service string, f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("catalog: hook panic for service %s: %v", service, r)
+		}
+	}()
+
+	f()
+}
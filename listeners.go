@@ -0,0 +1,184 @@
+package catalog
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	errNoListener       = errors.New("no address to listen on")
+	errAlreadyListening = errors.New("server is already listening")
+)
+
+// unixSocket is a unix domain socket served by the server
+type unixSocket struct {
+	path string
+	mode os.FileMode
+}
+
+// WithUnixSocket serves the unix domain socket on path besides the bindAddr, the file
+// gets the permissions of mode. A stale socket file of a previous run is removed.
+func WithUnixSocket(path string, mode os.FileMode) ServerOption {
+	return func(s *server) {
+		s.unixSockets = append(s.unixSockets, unixSocket{path: path, mode: mode})
+	}
+}
+
+// WithListener serves the already open listener besides the bindAddr, e.g. one of the
+// socket activation or a test harness. It is closed by Close.
+func WithListener(ln net.Listener) ServerOption {
+	return func(s *server) {
+		s.extra = append(s.extra, ln)
+	}
+}
+
+// listen opens the listeners of the server, empty bindAddr means no TCP listener.
+// The listeners are wrapped in TLS if it is configured. A concurrent or repeated
+// call returns errAlreadyListening without touching the bound listeners.
+func (s *server) listen() ([]net.Listener, error) {
+	s.mutex.Lock()
+	if s.binding || s.listening() {
+		s.mutex.Unlock()
+		return nil, errAlreadyListening
+	}
+	s.binding = true
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		s.binding = false
+		s.mutex.Unlock()
+	}()
+
+	// opened are the listeners bound here, the ones of the caller are
+	// closed only when the listening fails
+	var opened []net.Listener
+	var grpcListener net.Listener
+	var dnsServers []*dns.Server
+	var closeOpened = func() {
+		for _, ln := range opened {
+			ln.Close()
+		}
+		if grpcListener != nil {
+			grpcListener.Close()
+		}
+		closeDNS(dnsServers)
+	}
+	var fail = func(err error) ([]net.Listener, error) {
+		closeOpened()
+		for _, ln := range s.extra {
+			ln.Close()
+		}
+		s.setReady()
+		return nil, err
+	}
+
+	if s.bindAddr != "" {
+		ln, err := net.Listen("tcp", s.bindAddr)
+		if err != nil {
+			return fail(err)
+		}
+		opened = append(opened, ln)
+	}
+	for _, socket := range s.unixSockets {
+		ln, err := listenUnix(socket)
+		if err != nil {
+			return fail(err)
+		}
+		opened = append(opened, ln)
+	}
+	grpcListener, grpcServer, err := s.listenGRPC()
+	if err != nil {
+		return fail(err)
+	}
+	dnsServers, err = s.listenDNS()
+	if err != nil {
+		return fail(err)
+	}
+
+	var listeners = append(append([]net.Listener(nil), opened...), s.extra...)
+	if len(listeners) == 0 && grpcListener == nil && dnsServers == nil {
+		return fail(errNoListener)
+	}
+	if s.tlsConfig != nil {
+		for i, ln := range listeners {
+			listeners[i] = tls.NewListener(ln, s.tlsConfig)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return fail(net.ErrClosed)
+	}
+	if s.listening() {
+		closeOpened()
+		return nil, errAlreadyListening
+	}
+	s.listeners = listeners
+	s.grpcListener, s.grpcServer = grpcListener, grpcServer
+	s.dnsServers = dnsServers
+	s.setReady()
+	return listeners, nil
+}
+
+// setReady closes the ready channel once, after the listeners were bound
+// or the binding failed
+func (s *server) setReady() {
+	s.readyOnce.Do(func() {
+		close(s.ready)
+	})
+}
+
+// listening reports whether the listeners are bound, the mutex should be locked
+func (s *server) listening() bool {
+	return s.listeners != nil || s.grpcServer != nil || s.dnsServers != nil
+}
+
+func listenUnix(socket unixSocket) (net.Listener, error) {
+	info, err := os.Lstat(socket.path)
+	if err == nil && info.Mode()&os.ModeSocket != 0 {
+		err = os.Remove(socket.path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", socket.path)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(socket.path, socket.mode)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// closeListeners closes the listeners being served, the unix socket files are removed.
+// The gRPC server is stopped, its streams are cancelled, and the DNS servers are shut down.
+func (s *server) closeListeners() {
+	s.mutex.Lock()
+	for _, ln := range s.listeners {
+		ln.Close()
+	}
+	s.listeners = nil
+	grpcServer, dnsServers := s.grpcServer, s.dnsServers
+	s.grpcServer, s.grpcListener, s.dnsServers = nil, nil, nil
+	s.mutex.Unlock()
+
+	if grpcServer != nil {
+		grpcServer.Stop()
+	}
+	closeDNS(dnsServers)
+}
+
+func (s *server) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
@@ -0,0 +1,54 @@
+package catalog
+
+import "time"
+
+// Maintenance takes a service out of the healthy services without deregistering it,
+// like the maintenance mode of Consul
+type Maintenance struct {
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
+}
+
+// EnableMaintenance puts the service into maintenance, it is critical until
+// DisableMaintenance regardless of its checks, overrides and dependencies
+func (s *storage) EnableMaintenance(id Identifier, reason string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	service, ok := s.services[id]
+	if !ok {
+		return ErrUndefinedService
+	}
+
+	now := time.Now()
+	service.Maintenance = &Maintenance{Reason: reason, Since: now}
+	s.updateStatus(service, now)
+	return nil
+}
+
+// DisableMaintenance takes the service out of maintenance
+func (s *storage) DisableMaintenance(id Identifier) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	service, ok := s.services[id]
+	if !ok {
+		return ErrUndefinedService
+	}
+	if service.Maintenance == nil {
+		return nil
+	}
+
+	service.Maintenance = nil
+	s.updateStatus(service, time.Now())
+	return nil
+}
+
+// healthy reports whether the service should be in the healthy query results and the
+// DNS answers, the services without healthcheck are healthy like in Consul
+func (s *ServiceSpec) healthy() bool {
+	if s.Maintenance != nil {
+		return false
+	}
+	return s.Status == "" || s.IsAlive
+}
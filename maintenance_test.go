@@ -0,0 +1,132 @@
+package catalog
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	s := newTestServer(t)
+
+	var ids []Identifier
+	for _, port := range []int{9011, 9012} {
+		id, err := s.storage.Register("deployed", localhost, port, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.storage.AddCheck(id, Check{Name: "tcp", Interval: 10 * time.Millisecond, Func: AdaptCheck(func() (bool, error) {
+			return true, nil
+		})})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	var maintenanceResp MaintenanceResponse
+	err := s.maintenance(true, &MaintenanceRequest{ID: &ids[0], Reason: "deploy"}, &maintenanceResp)
+	if err != nil || !maintenanceResp.Success {
+		t.Fatalf("Maintenance should be enabled, instead of %v %s", err, maintenanceResp.Error)
+	}
+
+	// the passing check doesn't end the maintenance
+	time.Sleep(30 * time.Millisecond)
+	ss, err := s.storage.Service(&ids[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Maintenance == nil || ss.Maintenance.Reason != "deploy" || ss.Status != StatusCritical || ss.IsAlive {
+		t.Errorf("Service should be in maintenance, instead of %+v %s %v", ss.Maintenance, ss.Status, ss.IsAlive)
+	}
+
+	var servicesResp ServicesResponse
+	if err := s.services(&ServicesRequest{Healthy: true}, &servicesResp); err != nil {
+		t.Fatal(err)
+	}
+	if servicesResp.Total != 1 || servicesResp.Services[0].ID != ids[1] {
+		t.Errorf("Healthy services should contain only the other instance, instead of %v", servicesResp.Services)
+	}
+
+	err = s.maintenance(false, &MaintenanceRequest{ID: &ids[0]}, &maintenanceResp)
+	if err != nil || !maintenanceResp.Success {
+		t.Fatalf("Maintenance should be disabled, instead of %v %s", err, maintenanceResp.Error)
+	}
+	ss, err = s.storage.Service(&ids[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Maintenance != nil || ss.Status != StatusPassing {
+		t.Errorf("Service should be passing, instead of %+v %s", ss.Maintenance, ss.Status)
+	}
+}
+
+func TestMaintenanceIsNotReaped(t *testing.T) {
+	storage := NewStorage(nil, time.Hour, &sync.RWMutex{})
+	defer storage.Close()
+
+	id, err := storage.Register("upgrading", localhost, 9013, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SetDeregisterCriticalAfter(id, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	err = storage.AddCheck(id, Check{Name: "tcp", Interval: 10 * time.Millisecond, Func: AdaptCheck(func() (bool, error) {
+		return true, nil
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.EnableMaintenance(id, "upgrade"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the checks keep running while the service is critical by the maintenance
+	time.Sleep(100 * time.Millisecond)
+	ss, err := storage.Service(&id, nil)
+	if err != nil {
+		t.Fatal("Service in maintenance shouldn't be deregistered")
+	}
+	if ss.Status != StatusCritical || !ss.CriticalSince.IsZero() {
+		t.Errorf("Service should be critical without critical clock, instead of %s %v", ss.Status, ss.CriticalSince)
+	}
+}
+
+func TestMaintenanceByName(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, port := range []int{9016, 9017, 9018} {
+		if _, err := s.storage.Register("replicated", localhost, port, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var name = "replicated"
+	var maintenanceResp MaintenanceResponse
+	err := s.maintenance(true, &MaintenanceRequest{Name: &name, Reason: "rollout"}, &maintenanceResp)
+	if err != nil || !maintenanceResp.Success {
+		t.Fatalf("Maintenance should be enabled, instead of %v %s", err, maintenanceResp.Error)
+	}
+	for _, ss := range s.storage.Services() {
+		if ss.Maintenance == nil {
+			t.Errorf("Every instance should be in maintenance, %d isn't", ss.ID)
+		}
+	}
+
+	err = s.maintenance(false, &MaintenanceRequest{Name: &name}, &maintenanceResp)
+	if err != nil || !maintenanceResp.Success {
+		t.Fatalf("Maintenance should be disabled, instead of %v %s", err, maintenanceResp.Error)
+	}
+	for _, ss := range s.storage.Services() {
+		if ss.Maintenance != nil {
+			t.Errorf("No instance should be in maintenance, %d is", ss.ID)
+		}
+	}
+
+	var missing = "missing"
+	s.maintenance(true, &MaintenanceRequest{Name: &missing}, &maintenanceResp)
+	if maintenanceResp.Success || maintenanceResp.Error != ErrUndefinedService.Error() {
+		t.Errorf("Error should be %v, instead of %s", ErrUndefinedService, maintenanceResp.Error)
+	}
+}
@@ -1,24 +1,47 @@
 package catalog
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Request struct {
-	Cmd command `json:"cmd"`
+	Cmd Command `json:"cmd"`
 	Req string  `json:"req"`
+
+	// Token is the SecretID of the ACL token of the request
+	Token string `json:"token,omitempty"`
+
+	// ID is chosen by the client and echoed in the Response, the requests with ID
+	// can be pipelined on a connection and answered out of order
+	ID string `json:"id,omitempty"`
+
+	// Version of the protocol, zero means ProtocolV1. From ProtocolV2 the
+	// request is the Payload object instead of the Req string.
+	Version int             `json:"version,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	// codec of the connection and the one negotiated by the request
+	codec   Codec
+	upgrade Codec
+	// policy of the Token, nil if the ACLs are disabled
+	policy *Policy
 }
 
 type Response struct {
 	Resp string `json:"resp"`
-}
 
-// prepare the response
-func (r *Response) prepare() []byte {
-	resp, err := json.Marshal(r)
-	if err != nil {
-		panic(err)
-	}
+	// ID of the request
+	ID string `json:"id,omitempty"`
+
+	// Version of the protocol of the request, from ProtocolV2 the
+	// response is the Payload object instead of the Resp string
+	Version int             `json:"version,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
 
-	return append(resp, []byte(delimiter)...)
+	// Code of the error of a request refused before running its command,
+	// e.g. CodePermissionDenied
+	Code int `json:"code,omitempty"`
 }
 
 func NewResponse(resp []byte) Response {
@@ -32,6 +55,48 @@ type RegisterRequest struct {
 	Port       int         `json:"port"`
 	Tags       []string    `json:"tags"`
 	Additional interface{} `json:"additional"`
+
+	// Check is an optional healthcheck run by the server,
+	// same as Checks with a single check
+	Check *CheckDefinition `json:"check"`
+	// Checks are the named healthchecks run by the server, the status
+	// of the service computed from them with the HealthPolicy
+	Checks       []CheckDefinition `json:"checks"`
+	HealthPolicy HealthPolicy      `json:"health_policy"`
+
+	// DeregisterCriticalAfter removes the service after being critical for this long
+	DeregisterCriticalAfter time.Duration `json:"deregister_critical_after"`
+
+	// Dependencies on other services by name, they can't close a cycle
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+// definitions are the Check and the Checks of the request
+func (r *RegisterRequest) definitions() []CheckDefinition {
+	if r.Check == nil {
+		return r.Checks
+	}
+	return append([]CheckDefinition{*r.Check}, r.Checks...)
+}
+
+// checks builds the checks of the request for the service on address
+func (r *RegisterRequest) checks(address string) ([]Check, error) {
+	definitions := r.definitions()
+	var checks = make([]Check, 0, len(definitions))
+	var names = make(map[string]bool, len(definitions))
+	for _, definition := range definitions {
+		check, err := definition.Check(address)
+		if err != nil {
+			return nil, err
+		}
+		if names[check.Name] {
+			return nil, ErrDuplicateCheck
+		}
+		names[check.Name] = true
+		checks = append(checks, check)
+	}
+
+	return checks, nil
 }
 
 // RegisterResponse represent the register response to the server
@@ -40,16 +105,9 @@ type RegisterResponse struct {
 	Error   string          `json:"error"`
 	ID      Identifier      `json:"id"`
 	Meta    RegisterRequest `json:"meta"`
-}
-
-// prepare the response
-func (r *RegisterResponse) prepare() []byte {
-	resp, err := json.Marshal(r)
-	if err != nil {
-		panic(err)
-	}
-
-	return append(resp, []byte(delimiter)...)
+	// CheckFailures are the checks which didn't pass on their first run,
+	// the service is registered with them anyway
+	CheckFailures map[string]*CheckResult `json:"check_failures,omitempty"`
 }
 
 // DeregisterRequest represent the deregister request to the server
@@ -65,14 +123,183 @@ type DeregisterResponse struct {
 	Meta    DeregisterRequest `json:"meta"`
 }
 
-// prepare the response
-func (r *DeregisterResponse) prepare() []byte {
-	resp, err := json.Marshal(r)
-	if err != nil {
-		panic(err)
-	}
+// AddCheckRequest represent the request adding a check to the service identified by ID or Name
+type AddCheckRequest struct {
+	ID    *Identifier     `json:"id"`
+	Name  *string         `json:"name"`
+	Check CheckDefinition `json:"check"`
+}
+
+// AddCheckResponse represent the add check response to the server
+type AddCheckResponse struct {
+	Success bool            `json:"success"`
+	Error   string          `json:"error"`
+	Meta    AddCheckRequest `json:"meta"`
+	// CheckFailure is the result of the check if it didn't pass on its first run
+	CheckFailure *CheckResult `json:"check_failure,omitempty"`
+}
+
+// RemoveCheckRequest represent the request removing the named check of the service
+type RemoveCheckRequest struct {
+	ID    *Identifier `json:"id"`
+	Name  *string     `json:"name"`
+	Check string      `json:"check"`
+}
+
+// RemoveCheckResponse represent the remove check response to the server
+type RemoveCheckResponse struct {
+	Success bool               `json:"success"`
+	Error   string             `json:"error"`
+	Meta    RemoveCheckRequest `json:"meta"`
+}
+
+// HealthHistoryRequest represent the request of the latest check results of the service,
+// empty Check means all of the checks and zero Limit means all of the kept results
+type HealthHistoryRequest struct {
+	ID    *Identifier `json:"id"`
+	Name  *string     `json:"name"`
+	Check string      `json:"check"`
+	Limit int         `json:"limit"`
+}
+
+// HealthHistoryResponse represent the health history response to the server,
+// the records ordered from the oldest to the latest
+type HealthHistoryResponse struct {
+	Success bool                 `json:"success"`
+	Error   string               `json:"error"`
+	Meta    HealthHistoryRequest `json:"meta"`
+	Records []HealthRecord       `json:"records"`
+}
+
+// SetHealthRequest represent the request overriding the status of the service,
+// zero Duration means until cleared
+type SetHealthRequest struct {
+	ID       Identifier    `json:"id"`
+	Status   Status        `json:"status"`
+	Reason   string        `json:"reason"`
+	Duration time.Duration `json:"duration"`
+}
+
+// SetHealthResponse represent the set health response to the server
+type SetHealthResponse struct {
+	Success bool             `json:"success"`
+	Error   string           `json:"error"`
+	Meta    SetHealthRequest `json:"meta"`
+}
 
-	return append(resp, []byte(delimiter)...)
+// ClearOverrideRequest represent the request removing the health override of the service
+type ClearOverrideRequest struct {
+	ID Identifier `json:"id"`
+}
+
+// ClearOverrideResponse represent the clear override response to the server
+type ClearOverrideResponse struct {
+	Success bool                 `json:"success"`
+	Error   string               `json:"error"`
+	Meta    ClearOverrideRequest `json:"meta"`
+}
+
+// FaultsRequest represent the admin request replacing the injected faults,
+// empty Faults switch the injection off
+type FaultsRequest struct {
+	Faults []Fault `json:"faults"`
+}
+
+// FaultsResponse represent the faults response to the server
+type FaultsResponse struct {
+	Success bool          `json:"success"`
+	Error   string        `json:"error"`
+	Meta    FaultsRequest `json:"meta"`
+	Faults  []Fault       `json:"faults"`
+}
+
+// DependenciesRequest represent the request of the dependency graph of the services
+type DependenciesRequest struct {
+	// visible filters the services by the ACL policy of the request
+	visible func(name string) bool
+}
+
+// DependenciesResponse represent the dependencies response to the server
+type DependenciesResponse struct {
+	Success bool                `json:"success"`
+	Error   string              `json:"error"`
+	Meta    DependenciesRequest `json:"meta"`
+	Graph   DependencyGraph     `json:"graph"`
+}
+
+// MaintenanceRequest represent the request of the EnableMaintenance and DisableMaintenance
+// commands for the service identified by ID, or every instance of the service with the Name.
+// The Reason is used by EnableMaintenance only.
+type MaintenanceRequest struct {
+	ID     *Identifier `json:"id"`
+	Name   *string     `json:"name"`
+	Reason string      `json:"reason"`
+}
+
+// MaintenanceResponse represent the maintenance response to the server
+type MaintenanceResponse struct {
+	Success bool               `json:"success"`
+	Error   string             `json:"error"`
+	Meta    MaintenanceRequest `json:"meta"`
+}
+
+// HelloRequest represent the handshake of the client with the versions of the protocol it supports
+type HelloRequest struct {
+	Versions []int `json:"versions"`
+	// Codecs in the order of preference, the binary codecs need ProtocolV2
+	Codecs []string `json:"codecs"`
+}
+
+// HelloResponse represent the hello response to the server with the negotiated version
+type HelloResponse struct {
+	Success bool         `json:"success"`
+	Error   string       `json:"error"`
+	Meta    HelloRequest `json:"meta"`
+	Version int          `json:"version"`
+	Codec   string       `json:"codec"`
+}
+
+// ErrorResponse represent the response of a request refused before running its command
+type ErrorResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// ACLSetTokenRequest represent the admin request creating or replacing the token,
+// empty SecretID generates a new one
+type ACLSetTokenRequest struct {
+	Token Token `json:"token"`
+}
+
+// ACLSetTokenResponse represent the set token response to the server
+type ACLSetTokenResponse struct {
+	Success bool               `json:"success"`
+	Error   string             `json:"error"`
+	Meta    ACLSetTokenRequest `json:"meta"`
+	Token   Token              `json:"token"`
+}
+
+// ACLDeleteTokenRequest represent the admin request removing the token
+type ACLDeleteTokenRequest struct {
+	SecretID string `json:"secret_id"`
+}
+
+// ACLDeleteTokenResponse represent the delete token response to the server
+type ACLDeleteTokenResponse struct {
+	Success bool                  `json:"success"`
+	Error   string                `json:"error"`
+	Meta    ACLDeleteTokenRequest `json:"meta"`
+}
+
+// ACLTokensRequest represent the admin request of the tokens
+type ACLTokensRequest struct{}
+
+// ACLTokensResponse represent the tokens response to the server
+type ACLTokensResponse struct {
+	Success bool             `json:"success"`
+	Error   string           `json:"error"`
+	Meta    ACLTokensRequest `json:"meta"`
+	Tokens  []Token          `json:"tokens"`
 }
 
 type ServiceRequest struct {
@@ -87,32 +314,31 @@ type ServiceResponse struct {
 	Service ServiceSpec    `json:"service"`
 }
 
-// prepare the response
-func (r *ServiceResponse) prepare() []byte {
-	resp, err := json.Marshal(r)
-	if err != nil {
-		panic(err)
-	}
-
-	return append(resp, []byte(delimiter)...)
-}
+// Sort keys of the ServicesRequest
+const (
+	SortByName         = "name"
+	SortByID           = "id"
+	SortByRegistration = "registered_at"
+)
 
+// ServicesRequest represent the services request to the server,
+// empty SortBy means SortByName and zero Limit means no limit
 type ServicesRequest struct {
+	SortBy string `json:"sort_by"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	// Healthy returns only the alive services and the ones without healthcheck,
+	// the services in maintenance are left out
+	Healthy bool `json:"healthy"`
+
+	// visible filters the services by the ACL policy of the request
+	visible func(name string) bool
 }
 
 type ServicesResponse struct {
 	Success  bool            `json:"success"`
 	Error    string          `json:"error"`
 	Meta     ServicesRequest `json:"meta"`
+	Total    int             `json:"total"`
 	Services []ServiceSpec   `json:"services"`
 }
-
-// prepare the response
-func (r *ServicesResponse) prepare() []byte {
-	resp, err := json.Marshal(r)
-	if err != nil {
-		panic(err)
-	}
-
-	return append(resp, []byte(delimiter)...)
-}
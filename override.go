@@ -0,0 +1,86 @@
+package catalog
+
+import "time"
+
+// overrideKey schedules the end of the health override of a service,
+// the checks always have a name
+const overrideKey = ""
+
+// HealthOverride forces the status of a service regardless of its checks
+type HealthOverride struct {
+	Status Status `json:"status"`
+	Reason string `json:"reason"`
+	// Until is the end of the override, zero means until cleared
+	Until time.Time `json:"until"`
+}
+
+// SetHealth overrides the status of the service for the duration, zero duration means
+// until ClearOverride. The checks keep running and recording their results, the
+// service emits the same events as a real change of its status.
+func (s *storage) SetHealth(id Identifier, status Status, reason string, duration time.Duration) error {
+	switch status {
+	case StatusPassing, StatusWarning, StatusCritical:
+	default:
+		return ErrInvalidStatus
+	}
+	if duration < 0 {
+		return ErrInvalidOverrideDuration
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	service, ok := s.services[id]
+	if !ok {
+		return ErrUndefinedService
+	}
+
+	now := time.Now()
+	override := &HealthOverride{Status: status, Reason: reason}
+	key := checkKey{id: id, name: overrideKey}
+	if duration > 0 {
+		override.Until = now.Add(duration)
+		s.scheduler.after(key, duration, func() {
+			s.expireOverride(id, override)
+		})
+	} else {
+		s.scheduler.unschedule(key)
+	}
+	service.Override = override
+
+	s.updateStatus(service, now)
+	return nil
+}
+
+// ClearOverride removes the health override of the service, its status is computed
+// from its checks again
+func (s *storage) ClearOverride(id Identifier) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	service, ok := s.services[id]
+	if !ok {
+		return ErrUndefinedService
+	}
+	if service.Override == nil {
+		return nil
+	}
+
+	s.scheduler.unschedule(checkKey{id: id, name: overrideKey})
+	service.Override = nil
+	s.updateStatus(service, time.Now())
+	return nil
+}
+
+// expireOverride clears the override at the end of its duration if it wasn't replaced
+func (s *storage) expireOverride(id Identifier, override *HealthOverride) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	service, ok := s.services[id]
+	if !ok || service.Override != override {
+		return
+	}
+	service.Override = nil
+	s.updateStatus(service, time.Now())
+}
@@ -0,0 +1,87 @@
+package catalog
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealthOverride(t *testing.T) {
+	var changes = make(chan Status, 8)
+	storage := NewStorage(nil, time.Hour, &sync.RWMutex{})
+	defer storage.Close()
+	storage.SetHooks(Hooks{
+		OnHealthChange: func(service ServiceSpec) {
+			changes <- service.Status
+		},
+	})
+
+	id, err := storage.Register("dependency", localhost, 9007, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.AddCheck(id, Check{Name: "tcp", Interval: 10 * time.Millisecond, Func: AdaptCheck(func() (bool, error) {
+		return true, nil
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChange(t, changes, StatusPassing)
+
+	if err := storage.SetHealth(id, "down", "outage", 0); err != ErrInvalidStatus {
+		t.Errorf("Error should be %v, instead of %v", ErrInvalidStatus, err)
+	}
+
+	err = storage.SetHealth(id, StatusCritical, "simulated outage", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChange(t, changes, StatusCritical)
+
+	// the passing check doesn't change the overridden status
+	time.Sleep(30 * time.Millisecond)
+	ss, err := storage.Service(&id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Status != StatusCritical || ss.IsAlive || ss.Override == nil || ss.Override.Reason != "simulated outage" {
+		t.Errorf("Service should be overridden to critical, instead of %s %v %+v", ss.Status, ss.IsAlive, ss.Override)
+	}
+	if ss.Checks["tcp"].Status != StatusPassing {
+		t.Errorf("Check should keep its own status, instead of %s", ss.Checks["tcp"].Status)
+	}
+
+	// the override expires
+	expectChange(t, changes, StatusPassing)
+
+	err = storage.SetHealth(id, StatusWarning, "flapping", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChange(t, changes, StatusWarning)
+	err = storage.ClearOverride(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChange(t, changes, StatusPassing)
+
+	ss, err = storage.Service(&id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Override != nil {
+		t.Errorf("Override should be cleared, instead of %+v", ss.Override)
+	}
+}
+
+func expectChange(t *testing.T, changes chan Status, status Status) {
+	t.Helper()
+	select {
+	case changed := <-changes:
+		if changed != status {
+			t.Errorf("Status should change to %s, instead of %s", status, changed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Status should change to %s", status)
+	}
+}
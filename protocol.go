@@ -0,0 +1,119 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Versions of the protocol
+const (
+	// ProtocolV1 carries the payload as a JSON string in Req and Resp
+	ProtocolV1 = 1
+	// ProtocolV2 carries the payload as an embedded JSON object in Payload
+	ProtocolV2 = 2
+	// ProtocolVersion is the latest version of the protocol
+	ProtocolVersion = ProtocolV2
+)
+
+// CodeBadRequest is the Code of the responses of the requests which can't be decoded
+const CodeBadRequest = 400
+
+// decodeRequest decodes the request of a connection with the codec
+func decodeRequest(codec Codec, msg []byte) (Request, error) {
+	var req Request
+	err := codec.Unmarshal(msg, &req)
+	req.codec = codec
+	return req, err
+}
+
+// parsable reports whether the message is a value of the codec, even if it isn't a request
+func parsable(codec Codec, msg []byte) bool {
+	var v interface{}
+	return codec.Unmarshal(msg, &v) == nil
+}
+
+func (r *Request) codecOf() Codec {
+	if r.codec == nil {
+		return codecs[CodecJSON]
+	}
+	return r.codec
+}
+
+// payload returns the payload of the request in its version,
+// an empty v2 payload is an empty request
+func (r *Request) payload() []byte {
+	if r.Version < ProtocolV2 {
+		return []byte(r.Req)
+	}
+	if len(r.Payload) == 0 {
+		return []byte("null")
+	}
+	return r.Payload
+}
+
+// decodePayload decodes the payload of the request into v, the errors are ErrInvalidRequest
+func (r *Request) decodePayload(v interface{}) error {
+	codec := r.codecOf()
+	var err error
+	if codec.Name() == CodecJSON {
+		err = codec.Unmarshal(r.payload(), v)
+	} else if len(r.Payload) > 0 {
+		err = codec.Unmarshal(r.Payload, v)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return nil
+}
+
+// encodePayload encodes the payload of the response with the codec of the request
+func (r *Request) encodePayload(v interface{}) ([]byte, error) {
+	return r.codecOf().Marshal(v)
+}
+
+// response wraps the payload of the response in the version of the request,
+// the binary codecs always carry it in Payload
+func (r *Request) response(payload []byte) Response {
+	if r.Version < ProtocolV2 && r.codecOf().Name() == CodecJSON {
+		resp := NewResponse(payload)
+		resp.ID = r.ID
+		return resp
+	}
+	return Response{ID: r.ID, Version: r.Version, Payload: json.RawMessage(payload)}
+}
+
+// encodeResponse encodes the response into a frame of the codec of the request
+func (r *Request) encodeResponse(resp Response) ([]byte, error) {
+	msg, err := r.codecOf().Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return Frame(r.codecOf(), msg), nil
+}
+
+// hello negotiates the highest version of the protocol supported by both sides
+// and the first codec of the client supported by the server, JSON by default
+func (s *server) hello(req *HelloRequest, resp *HelloResponse) error {
+	resp.Meta = *req
+	for _, version := range req.Versions {
+		if version >= ProtocolV1 && version <= ProtocolVersion && version > resp.Version {
+			resp.Version = version
+		}
+	}
+	if resp.Version == 0 {
+		resp.Error = ErrUnsupportedVersion.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.Codec = CodecJSON
+	for _, name := range req.Codecs {
+		if _, ok := codecs[name]; ok && (name == CodecJSON || resp.Version >= ProtocolV2) {
+			resp.Codec = name
+			break
+		}
+	}
+
+	resp.Success = true
+	return nil
+}
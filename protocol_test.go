@@ -0,0 +1,59 @@
+package catalog
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestProtocolVersions(t *testing.T) {
+	s := newTestServer(t)
+
+	var cases = []struct {
+		versions []int
+		version  int
+	}{
+		{[]int{ProtocolV1, ProtocolV2, 3}, ProtocolV2},
+		{[]int{ProtocolV1}, ProtocolV1},
+		{[]int{3}, 0},
+	}
+	for _, c := range cases {
+		var helloResp HelloResponse
+		if err := s.hello(&HelloRequest{Versions: c.versions}, &helloResp); err != nil {
+			t.Fatal(err)
+		}
+		if helloResp.Version != c.version || helloResp.Success != (c.version != 0) {
+			t.Errorf("%v: version should be %d, instead of %d %s", c.versions, c.version, helloResp.Version, helloResp.Error)
+		}
+	}
+
+	// the v2 payload is an embedded object
+	message, err := s.handleRequest([]byte(`{"cmd":0,"version":2,"payload":{"name":"v2","address":"localhost","port":9013}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := json.Unmarshal(message, &resp); err != nil {
+		t.Fatal(err)
+	}
+	var registerResp RegisterResponse
+	if err := json.Unmarshal(resp.Payload, &registerResp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Version != ProtocolV2 || resp.Resp != "" || !registerResp.Success {
+		t.Errorf("Response should be v2, instead of %s", message)
+	}
+
+	// the v1 clients keep working
+	name := "v2"
+	reqJSON, _ := json.Marshal(ServiceRequest{Name: &name})
+	rJSON, _ := json.Marshal(Request{Cmd: Service, Req: string(reqJSON)})
+	message, err = s.handleRequest(rJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var serviceResp ServiceResponse
+	unmarshalResp(t, message, &serviceResp)
+	if !serviceResp.Success || serviceResp.Service.ID != registerResp.ID {
+		t.Errorf("v1 request should find the service, instead of %s", message)
+	}
+}
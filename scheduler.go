@@ -0,0 +1,117 @@
+package catalog
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// jitterFactor is the maximum deviation of the healthcheck interval,
+// it spreads the checks of the services registered at the same time
+const jitterFactor = 0.1
+
+// checkKey identifies a named check of a service
+type checkKey struct {
+	id   Identifier
+	name string
+}
+
+// scheduler runs every check of the services on its own interval
+type scheduler struct {
+	mutex  sync.Mutex
+	timers map[checkKey]*time.Timer
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		timers: make(map[checkKey]*time.Timer),
+	}
+}
+
+// schedule runs the check periodically, it replaces the previous schedule of the check
+func (sc *scheduler) schedule(key checkKey, interval time.Duration, check func()) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if timer, ok := sc.timers[key]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(withJitter(interval), func() {
+		check()
+
+		sc.mutex.Lock()
+		defer sc.mutex.Unlock()
+		// the check removed or rescheduled during the run
+		if sc.timers[key] != timer {
+			return
+		}
+		timer.Reset(withJitter(interval))
+	})
+	sc.timers[key] = timer
+}
+
+// after runs f once after the delay without jitter, it replaces the previous schedule of the key
+func (sc *scheduler) after(key checkKey, delay time.Duration, f func()) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if timer, ok := sc.timers[key]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		sc.mutex.Lock()
+		if sc.timers[key] == timer {
+			delete(sc.timers, key)
+		}
+		sc.mutex.Unlock()
+		f()
+	})
+	sc.timers[key] = timer
+}
+
+// unschedule stops the check
+func (sc *scheduler) unschedule(key checkKey) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if timer, ok := sc.timers[key]; ok {
+		timer.Stop()
+		delete(sc.timers, key)
+	}
+}
+
+// unscheduleService stops all of the checks of the service
+func (sc *scheduler) unscheduleService(id Identifier) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	for key, timer := range sc.timers {
+		if key.id == id {
+			timer.Stop()
+			delete(sc.timers, key)
+		}
+	}
+}
+
+// stop stops all of the healthchecks
+func (sc *scheduler) stop() {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	for key, timer := range sc.timers {
+		timer.Stop()
+		delete(sc.timers, key)
+	}
+}
+
+func withJitter(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return interval
+	}
+	jitter := time.Duration((rand.Float64()*2 - 1) * jitterFactor * float64(interval))
+	return interval + jitter
+}
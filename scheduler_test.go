@@ -0,0 +1,79 @@
+package catalog
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerIntervals(t *testing.T) {
+	var fast, slow int32
+	hcStorage := func(name string) (time.Duration, func() (bool, error)) {
+		switch name {
+		case "fast":
+			return 20 * time.Millisecond, func() (bool, error) {
+				atomic.AddInt32(&fast, 1)
+				return true, nil
+			}
+		case "slow":
+			return time.Hour, func() (bool, error) {
+				atomic.AddInt32(&slow, 1)
+				return true, nil
+			}
+		}
+		return 0, nil
+	}
+
+	storage := NewStorage(hcStorage, 2000*time.Millisecond, &sync.RWMutex{})
+	defer storage.Close()
+
+	fastID, err := storage.Register("fast", "localhost", 9001, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.Register("slow", "localhost", 9002, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := storage.Service(&fastID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.HealthcheckInterval != 20*time.Millisecond {
+		t.Errorf("Interval should be 20ms, instead of %v", service.HealthcheckInterval)
+	}
+
+	var deadline = time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&fast) < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&fast); n < 4 {
+		t.Errorf("Fast check should run at least 4 times, instead of %d", n)
+	}
+	// only the check during the registration
+	if n := atomic.LoadInt32(&slow); n != 1 {
+		t.Errorf("Slow check should run once, instead of %d", n)
+	}
+
+	err = storage.Deregister(&fastID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	stopped := atomic.LoadInt32(&fast)
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&fast); n != stopped {
+		t.Errorf("Check should stop after deregister, ran %d more times", n-stopped)
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := withJitter(time.Second)
+		if d < 900*time.Millisecond || d > 1100*time.Millisecond {
+			t.Errorf("Jitter out of range: %v", d)
+		}
+	}
+}
@@ -2,22 +2,42 @@ package catalog
 
 import (
 	"bufio"
-	"encoding/json"
+	"bytes"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/miekg/dns"
+	"google.golang.org/grpc"
 )
 
-type command uint8
+// Command is the command of a Request
+type Command uint8
 
 const (
-	Register command = iota
+	Register Command = iota
 	Deregister
 	Services
 	Service
+	AddCheck
+	RemoveCheck
+	HealthHistory
+	SetHealth
+	ClearOverride
+	Faults
+	Dependencies
+	EnableMaintenance
+	DisableMaintenance
+	Hello
+	ACLSetToken
+	ACLDeleteToken
+	ACLTokens
 )
 
 const delimiter = "\n"
@@ -26,104 +46,303 @@ const delimiterByte = '\n'
 // Server represent the standalone service
 type Server interface {
 	Listen() error
+	// Start binds the listeners and serves them in the background
+	Start() error
+	// Addr is the address of the first listener, the TCP one if there is,
+	// nil until the listeners are bound
+	Addr() net.Addr
+	// Ready is closed when the listeners are bound, or when the first binding
+	// failed or the server was closed before, Addr is nil then
+	Ready() <-chan struct{}
+	// GRPCAddr is the address of the gRPC listener, nil without WithGRPC
+	GRPCAddr() net.Addr
+	// DNSAddr is the UDP address of the DNS interface, nil without WithDNS
+	DNSAddr() net.Addr
 	Close()
 }
 
 // server is the main handler struct
 type server struct {
 	// add logger
-	bindAddr         string
-	storage          Storage
-	closeCh          chan bool
-	healthcheckMutex *sync.RWMutex
+	bindAddr string
+	storage  Storage
+	faults   *faultInjector
+
+	// unixSockets and the listeners of the caller are served besides bindAddr
+	unixSockets []unixSocket
+	extra       []net.Listener
+	// listeners being served, closed by Close
+	mutex     sync.Mutex
+	listeners []net.Listener
+	closed    bool
+	ready     chan struct{}
+	readyOnce sync.Once
+	// binding is set while listen binds the listeners
+	binding bool
+
+	// grpcAddr of the gRPC API, empty means it isn't served
+	grpcAddr     string
+	grpcListener net.Listener
+	grpcServer   *grpc.Server
+
+	// dnsAddr of the DNS interface, empty means it isn't served
+	dnsAddr    string
+	dnsToken   string
+	dnsServers []*dns.Server
+
+	// tlsConfig of the listener, nil means plain TCP
+	tlsConfig *tls.Config
+	// acl holds the tokens, nil means the ACLs are disabled
+	acl *acl
+	// execChecks allows the clients to register exec checks
+	execChecks bool
+}
+
+// ServerOption configures the Server
+type ServerOption func(s *server)
+
+// WithTLS serves the connections over TLS, the config with ClientAuth
+// tls.RequireAndVerifyClientCert and ClientCAs accepts only the clients
+// with a certificate signed by the CA (mutual TLS)
+func WithTLS(config *tls.Config) ServerOption {
+	return func(s *server) {
+		s.tlsConfig = config
+	}
+}
+
+// WithACL requires a token with a policy granting the access on every request,
+// the masterToken is an admin token creating the other tokens
+func WithACL(masterToken string) ServerOption {
+	return func(s *server) {
+		s.acl = newACL(masterToken)
+	}
 }
 
-func NewServer(bindAddr string, healthcheckStorage func(name string) (time.Duration, func() (bool, error)), mutex *sync.RWMutex) Server {
+// WithExecChecks allows the exec checks in the requests of the clients, they run any
+// command on the server, so they are rejected by default like Consul's enable_script_checks
+func WithExecChecks() ServerOption {
+	return func(s *server) {
+		s.execChecks = true
+	}
+}
+
+func NewServer(bindAddr string, healthcheckStorage func(name string) (time.Duration, func() (bool, error)), mutex *sync.RWMutex, options ...ServerOption) Server {
 	// @TODO handle if mutex is nil
-	closeCh := make(chan bool, 1)
 	s := new(server)
 	s.storage = NewStorage(healthcheckStorage, 2000*time.Millisecond, mutex)
+	s.faults = newFaultInjector()
 	s.bindAddr = bindAddr
-	s.closeCh = closeCh
-	s.healthcheckMutex = mutex
+	s.ready = make(chan struct{})
+	for _, option := range options {
+		option(s)
+	}
 
 	return s
 }
 
+// Listen serves the TCP bindAddr, the unix sockets and the listeners of the caller
+// until Close or the first failing listener, the other listeners are closed then
 func (s *server) Listen() error {
-	ln, err := net.Listen("tcp", s.bindAddr)
+	listeners, err := s.listen()
 	if err != nil {
 		fmt.Println("0", err)
 		return err
 	}
+	return s.acceptAll(listeners)
+}
 
-	go func() {
-		for {
-			err := s.storage.Healthcheck(s.healthcheckMutex)
-			if err != nil {
-				log.Print(err)
-				s.Close()
-			}
+// Start binds the listeners like Listen and serves them in the background, so the
+// bindAddr can be :0 and the chosen port read from Addr after it returned
+func (s *server) Start() error {
+	listeners, err := s.listen()
+	if err != nil {
+		return err
+	}
 
-			// 10ms delay to stable provide concurrent map read and write
-			time.Sleep(10 * time.Millisecond)
-			time.Sleep(s.storage.HealthcheckPeriod())
+	go func() {
+		err := s.acceptAll(listeners)
+		if err != nil {
+			log.Printf("catalog: %v", err)
 		}
 	}()
+	return nil
+}
 
-	for {
-		select {
-		case <-s.closeCh:
-			fmt.Println("closed")
-			return nil
-		default:
-		}
+func (s *server) Addr() net.Addr {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.listeners) == 0 {
+		return nil
+	}
+	return s.listeners[0].Addr()
+}
 
-		conn, err := ln.Accept()
+func (s *server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// acceptAll serves the listeners until Close or the first failing one
+func (s *server) acceptAll(listeners []net.Listener) error {
+	s.mutex.Lock()
+	grpcListener, grpcServer := s.grpcListener, s.grpcServer
+	dnsServers := s.dnsServers
+	s.mutex.Unlock()
+
+	var errCh = make(chan error, len(listeners)+len(dnsServers)+1)
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			errCh <- s.accept(ln)
+		}(ln)
+	}
+	if grpcServer != nil {
+		go func() {
+			errCh <- grpcServer.Serve(grpcListener)
+		}()
+	}
+	for _, ds := range dnsServers {
+		go func(ds *dns.Server) {
+			err := ds.ActivateAndServe()
+			if s.isClosed() {
+				err = nil
+			}
+			errCh <- err
+		}(ds)
+	}
+	err := <-errCh
+	s.closeListeners()
+	return err
+}
 
-		if err != nil && err != io.EOF {
+// accept serves the connections of the listener until it fails
+func (s *server) accept(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				fmt.Println("closed")
+				return nil
+			}
 			fmt.Println("1", err)
 			return err
 		}
 
-		msg, err := bufio.NewReader(conn).ReadBytes('\n')
-		if err != nil && err != io.EOF {
-			fmt.Println("2", err)
-			return err
+		go s.serve(conn)
+	}
+}
+
+// serve handles the requests of the connection until the client closes it. The requests
+// with ID are handled concurrently and their responses might be written out of order,
+// the ones without ID are handled in order. A request which can't be decoded is answered
+// with CodeBadRequest, the connection is closed only by a frame which can't be parsed
+// or an internal error.
+func (s *server) serve(conn net.Conn) {
+	var writeMutex sync.Mutex
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		conn.Close()
+	}()
+
+	var write = func(resp []byte, err error) {
+		if err != nil && err != errCloseConnection {
+			log.Printf("catalog: request from %s: %v", conn.RemoteAddr(), err)
+			conn.Close()
+			return
 		}
 
-		resp, err := s.handleRequest(msg)
-		if err != nil {
-			fmt.Println("3", err)
-			return err
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		_, werr := conn.Write(resp)
+		if werr != nil || err == errCloseConnection {
+			conn.Close()
+		}
+	}
+	var handle = func(req *Request) {
+		resp, err := s.handleDecoded(req)
+		if errors.Is(err, ErrInvalidRequest) {
+			// the other requests of the connection go on
+			resp, err = req.deny(CodeBadRequest, err)
 		}
+		write(resp, err)
+	}
 
-		_, err = conn.Write(resp)
+	// the connection starts with JSON lines, the Hello command might switch the codec
+	var codec = codecs[CodecJSON]
+	reader := bufio.NewReader(conn)
+	for {
+		msg, err := ReadFrame(reader, codec)
+		if len(bytes.TrimSpace(msg)) > 0 {
+			req, derr := decodeRequest(codec, msg)
+			switch {
+			case derr != nil && !parsable(codec, msg):
+				log.Printf("catalog: request from %s: %v", conn.RemoteAddr(), derr)
+				return
+			case derr != nil:
+				// the fields decoded before the error, like the ID, are kept for the response
+				write(req.deny(CodeBadRequest, fmt.Errorf("%w: %v", ErrInvalidRequest, derr)))
+			case req.ID == "" || req.Cmd == Hello:
+				handle(&req)
+				if req.upgrade != nil {
+					codec = req.upgrade
+				}
+			default:
+				wg.Add(1)
+				go func(req *Request) {
+					defer wg.Done()
+					handle(req)
+				}(&req)
+			}
+		}
 		if err != nil {
-			fmt.Println("4", err)
-			return err
+			return
 		}
 	}
 }
 
 func (s *server) Close() {
-	s.closeCh <- true
+	s.storage.Close()
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+	s.closeListeners()
+	// the ones waiting for a Listen which won't bind anymore are released
+	s.setReady()
 	return
 }
 
+// handleRequest handles a JSON request
 func (s *server) handleRequest(reqByte []byte) ([]byte, error) {
-	var req Request
-	var resp Response
+	req, err := decodeRequest(codecs[CodecJSON], reqByte)
+	if err != nil {
+		return nil, err
+	}
+	return s.handleDecoded(&req)
+}
 
-	err := json.Unmarshal(reqByte, &req)
+func (s *server) handleDecoded(req *Request) ([]byte, error) {
+	err := s.authorize(req)
+	if err == ErrPermissionDenied {
+		return req.deny(CodePermissionDenied, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// the faults can always be switched off and the protocol negotiated
+	if req.Cmd == Faults || req.Cmd == Hello {
+		return s.handle(req)
+	}
+	return s.handleWithFaults(req)
+}
+
+func (s *server) handle(req *Request) ([]byte, error) {
+	var resp Response
+	var err error
+
 	switch req.Cmd {
 	case Register:
 		var registerReq RegisterRequest
-		err = json.Unmarshal([]byte(req.Req), &registerReq)
+		err = req.decodePayload(&registerReq)
 		if err != nil {
 			return nil, err
 		}
@@ -134,14 +353,14 @@ func (s *server) handleRequest(reqByte []byte) ([]byte, error) {
 			return nil, err
 		}
 
-		respJSON, err := json.Marshal(registerResp)
+		respJSON, err := req.encodePayload(registerResp)
 		if err != nil {
 			return nil, err
 		}
-		resp = NewResponse(respJSON)
+		resp = req.response(respJSON)
 	case Deregister:
 		var deregisterReq DeregisterRequest
-		err = json.Unmarshal([]byte(req.Req), &deregisterReq)
+		err = req.decodePayload(&deregisterReq)
 		if err != nil {
 			return nil, err
 		}
@@ -152,14 +371,14 @@ func (s *server) handleRequest(reqByte []byte) ([]byte, error) {
 			return nil, err
 		}
 
-		respJSON, err := json.Marshal(deregisterResp)
+		respJSON, err := req.encodePayload(deregisterResp)
 		if err != nil {
 			return nil, err
 		}
-		resp = NewResponse(respJSON)
+		resp = req.response(respJSON)
 	case Service:
 		var serviceReq ServiceRequest
-		err = json.Unmarshal([]byte(req.Req), &serviceReq)
+		err = req.decodePayload(&serviceReq)
 		if err != nil {
 			return nil, err
 		}
@@ -170,17 +389,18 @@ func (s *server) handleRequest(reqByte []byte) ([]byte, error) {
 			return nil, err
 		}
 
-		respJSON, err := json.Marshal(serviceResp)
+		respJSON, err := req.encodePayload(serviceResp)
 		if err != nil {
 			return nil, err
 		}
-		resp = NewResponse(respJSON)
+		resp = req.response(respJSON)
 	case Services:
 		var servicesReq ServicesRequest
-		err = json.Unmarshal([]byte(req.Req), &servicesReq)
+		err = req.decodePayload(&servicesReq)
 		if err != nil {
 			return nil, err
 		}
+		servicesReq.visible = req.readable
 
 		var servicesResp ServicesResponse
 		err := s.services(&servicesReq, &servicesResp)
@@ -188,28 +408,506 @@ func (s *server) handleRequest(reqByte []byte) ([]byte, error) {
 			return nil, err
 		}
 
-		respJSON, err := json.Marshal(servicesResp)
+		respJSON, err := req.encodePayload(servicesResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case AddCheck:
+		var addCheckReq AddCheckRequest
+		err = req.decodePayload(&addCheckReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var addCheckResp AddCheckResponse
+		err := s.addCheck(&addCheckReq, &addCheckResp)
+		if err != nil {
+			return nil, err
+		}
+
+		respJSON, err := req.encodePayload(addCheckResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case RemoveCheck:
+		var removeCheckReq RemoveCheckRequest
+		err = req.decodePayload(&removeCheckReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var removeCheckResp RemoveCheckResponse
+		err := s.removeCheck(&removeCheckReq, &removeCheckResp)
+		if err != nil {
+			return nil, err
+		}
+
+		respJSON, err := req.encodePayload(removeCheckResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case HealthHistory:
+		var historyReq HealthHistoryRequest
+		err = req.decodePayload(&historyReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var historyResp HealthHistoryResponse
+		err := s.healthHistory(&historyReq, &historyResp)
+		if err != nil {
+			return nil, err
+		}
+
+		respJSON, err := req.encodePayload(historyResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case SetHealth:
+		var setHealthReq SetHealthRequest
+		err = req.decodePayload(&setHealthReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var setHealthResp SetHealthResponse
+		err := s.setHealth(&setHealthReq, &setHealthResp)
+		if err != nil {
+			return nil, err
+		}
+
+		respJSON, err := req.encodePayload(setHealthResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case ClearOverride:
+		var clearOverrideReq ClearOverrideRequest
+		err = req.decodePayload(&clearOverrideReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var clearOverrideResp ClearOverrideResponse
+		err := s.clearOverride(&clearOverrideReq, &clearOverrideResp)
+		if err != nil {
+			return nil, err
+		}
+
+		respJSON, err := req.encodePayload(clearOverrideResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case Faults:
+		var faultsReq FaultsRequest
+		err = req.decodePayload(&faultsReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var faultsResp FaultsResponse
+		err := s.setFaults(&faultsReq, &faultsResp)
+		if err != nil {
+			return nil, err
+		}
+
+		respJSON, err := req.encodePayload(faultsResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case Dependencies:
+		var dependenciesReq DependenciesRequest
+		err = req.decodePayload(&dependenciesReq)
+		if err != nil {
+			return nil, err
+		}
+		dependenciesReq.visible = req.readable
+
+		var dependenciesResp DependenciesResponse
+		err := s.dependencies(&dependenciesReq, &dependenciesResp)
+		if err != nil {
+			return nil, err
+		}
+
+		respJSON, err := req.encodePayload(dependenciesResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case EnableMaintenance, DisableMaintenance:
+		var maintenanceReq MaintenanceRequest
+		err = req.decodePayload(&maintenanceReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var maintenanceResp MaintenanceResponse
+		err := s.maintenance(req.Cmd == EnableMaintenance, &maintenanceReq, &maintenanceResp)
+		if err != nil {
+			return nil, err
+		}
+
+		respJSON, err := req.encodePayload(maintenanceResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case Hello:
+		var helloReq HelloRequest
+		err = req.decodePayload(&helloReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var helloResp HelloResponse
+		err := s.hello(&helloReq, &helloResp)
+		if err != nil {
+			return nil, err
+		}
+		// the response is in the current codec, the next requests in the negotiated one
+		if helloResp.Success && helloResp.Codec != req.codecOf().Name() {
+			req.upgrade = codecs[helloResp.Codec]
+		}
+
+		respJSON, err := req.encodePayload(helloResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case ACLSetToken:
+		var setTokenReq ACLSetTokenRequest
+		err = req.decodePayload(&setTokenReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var setTokenResp ACLSetTokenResponse
+		err := s.setToken(&setTokenReq, &setTokenResp)
+		if err != nil {
+			return nil, err
+		}
+
+		respJSON, err := req.encodePayload(setTokenResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case ACLDeleteToken:
+		var deleteTokenReq ACLDeleteTokenRequest
+		err = req.decodePayload(&deleteTokenReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var deleteTokenResp ACLDeleteTokenResponse
+		err := s.deleteToken(&deleteTokenReq, &deleteTokenResp)
 		if err != nil {
 			return nil, err
 		}
-		resp = NewResponse(respJSON)
+
+		respJSON, err := req.encodePayload(deleteTokenResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
+	case ACLTokens:
+		var tokensReq ACLTokensRequest
+		err = req.decodePayload(&tokensReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var tokensResp ACLTokensResponse
+		err := s.tokens(&tokensReq, &tokensResp)
+		if err != nil {
+			return nil, err
+		}
+
+		respJSON, err := req.encodePayload(tokensResp)
+		if err != nil {
+			return nil, err
+		}
+		resp = req.response(respJSON)
 	}
 
-	return resp.prepare(), nil
+	return req.encodeResponse(resp)
 }
 
 func (s *server) register(req *RegisterRequest, resp *RegisterResponse) error {
-	id, err := s.storage.Register(req.Name, req.Address, req.Port, req.Tags, req.Additional)
 	resp.Meta = *req
+
+	// invalid checks are client errors, reported in the response only
+	checks, err := req.checks(net.JoinHostPort(req.Address, strconv.Itoa(req.Port)))
+	if err == nil {
+		err = s.allowChecks(req.definitions()...)
+	}
+	if err == nil {
+		err = req.HealthPolicy.Validate()
+	}
+	if err == nil && req.DeregisterCriticalAfter < 0 {
+		err = ErrInvalidDeregisterCriticalAfter
+	}
+	for _, dependency := range req.Dependencies {
+		if err == nil && dependency.Name == "" {
+			err = ErrInvalidDependency
+		}
+	}
+	if err == nil && s.storage.DependencyGraph().cycle(req.Name, req.Dependencies) {
+		err = ErrDependencyCycle
+	}
 	if err != nil {
 		resp.Error = err.Error()
 		resp.Success = false
-		return err
+		return nil
 	}
 
+	id, err := s.storage.Register(req.Name, req.Address, req.Port, req.Tags, req.Additional)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	if len(checks) > 0 {
+		err = s.storage.SetHealthPolicy(id, req.HealthPolicy)
+		if err != nil {
+			log.Print(err)
+		}
+	}
+	if req.DeregisterCriticalAfter > 0 {
+		err = s.storage.SetDeregisterCriticalAfter(id, req.DeregisterCriticalAfter)
+		if err != nil {
+			log.Print(err)
+		}
+	}
+	if len(req.Dependencies) > 0 {
+		err = s.storage.SetDependencies(id, req.Dependencies)
+		if err != nil {
+			log.Print(err)
+		}
+	}
+	for _, check := range checks {
+		err = s.storage.AddCheck(id, check)
+		if err == ErrUndefinedService {
+			break
+		}
+	}
+
+	// the failing checks, including the one of the healthcheck storage, are
+	// recorded as the status of the service and reported to the client
+	resp.CheckFailures = checkFailures(s.storage, id, nil)
 	resp.ID = id
 	resp.Success = true
-	return err
+	return nil
+}
+
+// addCheck adds the check to the service, the errors are reported in the response only
+func (s *server) addCheck(req *AddCheckRequest, resp *AddCheckResponse) error {
+	resp.Meta = *req
+	ss, err := s.storage.Service(req.ID, req.Name)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	check, err := req.Check.Check(ss.Address)
+	if err == nil {
+		err = s.allowChecks(req.Check)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	// the first run of the check might fail, the check is set up anyway
+	err = s.storage.AddCheck(ss.ID, check)
+	if err == ErrUndefinedService || err == ErrInvalidCheck {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.CheckFailure = checkFailures(s.storage, ss.ID, &check.Name)[check.Name]
+	resp.Success = true
+	return nil
+}
+
+// allowChecks rejects the exec checks unless they are enabled by WithExecChecks
+func (s *server) allowChecks(definitions ...CheckDefinition) error {
+	for _, definition := range definitions {
+		if definition.Type == CheckExec && !s.execChecks {
+			return ErrExecChecksDisabled
+		}
+	}
+	return nil
+}
+
+// checkFailures returns the results of the checks of the service which aren't passing,
+// nil name means all of the checks
+func checkFailures(storage Storage, id Identifier, name *string) map[string]*CheckResult {
+	ss, err := storage.Service(&id, nil)
+	if err != nil {
+		return nil
+	}
+
+	var failures map[string]*CheckResult
+	for check, result := range ss.Checks {
+		if result.Status == StatusPassing || (name != nil && check != *name) {
+			continue
+		}
+		if failures == nil {
+			failures = make(map[string]*CheckResult)
+		}
+		failures[check] = result
+	}
+	return failures
+}
+
+// removeCheck removes the check of the service, the errors are reported in the response only
+func (s *server) removeCheck(req *RemoveCheckRequest, resp *RemoveCheckResponse) error {
+	resp.Meta = *req
+	ss, err := s.storage.Service(req.ID, req.Name)
+	if err == nil {
+		err = s.storage.RemoveCheck(ss.ID, req.Check)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.Success = true
+	return nil
+}
+
+// setHealth overrides the status of the service, the errors are reported in the response only
+func (s *server) setHealth(req *SetHealthRequest, resp *SetHealthResponse) error {
+	resp.Meta = *req
+	err := s.storage.SetHealth(req.ID, req.Status, req.Reason, req.Duration)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.Success = true
+	return nil
+}
+
+// clearOverride removes the health override of the service, the errors are reported in the response only
+func (s *server) clearOverride(req *ClearOverrideRequest, resp *ClearOverrideResponse) error {
+	resp.Meta = *req
+	err := s.storage.ClearOverride(req.ID)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.Success = true
+	return nil
+}
+
+// setFaults replaces the injected faults, the errors are reported in the response only
+func (s *server) setFaults(req *FaultsRequest, resp *FaultsResponse) error {
+	resp.Meta = *req
+	err := s.faults.set(req.Faults)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.Faults = s.faults.list()
+	resp.Success = true
+	return nil
+}
+
+// maintenance enables or disables the maintenance of the service, the errors are reported in the response only
+func (s *server) maintenance(enable bool, req *MaintenanceRequest, resp *MaintenanceResponse) error {
+	resp.Meta = *req
+	ids, err := s.instances(req.ID, req.Name)
+	for _, id := range ids {
+		if err != nil {
+			break
+		}
+		if enable {
+			err = s.storage.EnableMaintenance(id, req.Reason)
+		} else {
+			err = s.storage.DisableMaintenance(id)
+		}
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.Success = true
+	return nil
+}
+
+// instances resolves the service identified by ID, or every instance of the service by name
+func (s *server) instances(id *Identifier, name *string) ([]Identifier, error) {
+	if id != nil || name == nil {
+		ss, err := s.storage.Service(id, name)
+		if err != nil {
+			return nil, err
+		}
+		return []Identifier{ss.ID}, nil
+	}
+
+	var ids []Identifier
+	for _, ss := range s.storage.Services() {
+		if ss.Name == *name {
+			ids = append(ids, ss.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, ErrUndefinedService
+	}
+	return ids, nil
+}
+
+// dependencies returns the dependency graph of the services
+func (s *server) dependencies(req *DependenciesRequest, resp *DependenciesResponse) error {
+	resp.Meta = *req
+	resp.Graph = s.storage.DependencyGraph()
+	if req.visible != nil {
+		for name := range resp.Graph {
+			if !req.visible(name) {
+				delete(resp.Graph, name)
+			}
+		}
+	}
+	resp.Success = true
+	return nil
+}
+
+// healthHistory returns the latest check results of the service, the errors are reported in the response only
+func (s *server) healthHistory(req *HealthHistoryRequest, resp *HealthHistoryResponse) error {
+	resp.Meta = *req
+	records, err := s.storage.HealthHistory(req.ID, req.Name, req.Check)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	if req.Limit > 0 && req.Limit < len(records) {
+		records = records[len(records)-req.Limit:]
+	}
+	resp.Records = records
+	resp.Success = true
+	return nil
 }
 
 func (s *server) deregister(req *DeregisterRequest, resp *DeregisterResponse) error {
@@ -218,7 +916,7 @@ func (s *server) deregister(req *DeregisterRequest, resp *DeregisterResponse) er
 	if err != nil {
 		resp.Error = err.Error()
 		resp.Success = false
-		return err
+		return nil
 	}
 
 	resp.Success = true
@@ -235,14 +933,14 @@ func (s *server) service(req *ServiceRequest, resp *ServiceResponse) error {
 	} else {
 		resp.Error = ErrServiceRequestInvalid.Error()
 		resp.Success = false
-		return ErrServiceRequestInvalid
+		return nil
 	}
 
 	resp.Meta = *req
 	if err != nil {
 		resp.Error = err.Error()
 		resp.Success = false
-		return err
+		return nil
 	}
 
 	resp.Service = *ss
@@ -251,14 +949,78 @@ func (s *server) service(req *ServiceRequest, resp *ServiceResponse) error {
 }
 
 func (s *server) services(req *ServicesRequest, resp *ServicesResponse) error {
+	// invalid parameters are client errors, they are reported
+	// in the response only and don't stop the server
+	resp.Meta = *req
+	if req.Limit < 0 || req.Offset < 0 {
+		resp.Error = ErrInvalidPagination.Error()
+		resp.Success = false
+		return nil
+	}
+
 	specs := s.storage.Services()
 	var container []ServiceSpec
 	for _, spec := range specs {
+		if req.Healthy && !spec.healthy() {
+			continue
+		}
+		if req.visible != nil && !req.visible(spec.Name) {
+			continue
+		}
 		container = append(container, *spec)
 	}
-	resp.Meta = *req
+
+	err := sortServices(container, req.SortBy)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		return nil
+	}
+
+	resp.Total = len(container)
 	resp.Success = true
-	resp.Services = container
+	resp.Services = paginate(container, req.Limit, req.Offset)
 
 	return nil
 }
+
+// sortServices sort the services by the given key, ties are ordered by ID
+// so the result is always deterministic
+func sortServices(specs []ServiceSpec, key string) error {
+	var less func(a, b *ServiceSpec) bool
+	switch key {
+	case "", SortByName:
+		less = func(a, b *ServiceSpec) bool { return a.Name < b.Name }
+	case SortByID:
+		less = func(a, b *ServiceSpec) bool { return false }
+	case SortByRegistration:
+		less = func(a, b *ServiceSpec) bool { return a.RegisteredAt.Before(b.RegisteredAt) }
+	default:
+		return ErrInvalidSortKey
+	}
+
+	sort.Slice(specs, func(i, j int) bool {
+		if less(&specs[i], &specs[j]) {
+			return true
+		}
+		if less(&specs[j], &specs[i]) {
+			return false
+		}
+		return specs[i].ID < specs[j].ID
+	})
+
+	return nil
+}
+
+// paginate returns the page of the services, limit 0 means all of them
+func paginate(specs []ServiceSpec, limit int, offset int) []ServiceSpec {
+	if offset >= len(specs) {
+		return []ServiceSpec{}
+	}
+	specs = specs[offset:]
+	if limit > 0 && limit < len(specs) {
+		specs = specs[:limit]
+	}
+
+	return specs
+}
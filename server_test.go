@@ -4,12 +4,16 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
-var binAddr = "127.0.0.1:8878"
+// binAddr is the address of serv, bound to a free port
+var binAddr string
 var serv Server
 var testCounter = 2
 var idOfServices []Identifier
@@ -43,23 +47,29 @@ var testServices = []*struct {
 	},
 }
 
-func init() {
+// newTestServer is a server without listeners, it is closed at the end of the test
+func newTestServer(t *testing.T, options ...ServerOption) *server {
+	t.Helper()
+	s := NewServer("", nil, &sync.RWMutex{}, options...).(*server)
+	t.Cleanup(s.Close)
+	return s
+}
 
-	go func() {
-		serv = NewServer(binAddr, nil, mutex)
-		err := serv.Listen()
-		if err != nil {
-			fmt.Println(err)
-		}
-	}()
+// withHealthcheckStorage replaces the storage of the test server with one using the healthcheck storage
+func withHealthcheckStorage(healthcheckStorage func(name string) (time.Duration, func() (bool, error))) ServerOption {
+	return func(s *server) {
+		s.storage.Close()
+		s.storage = NewStorage(healthcheckStorage, time.Hour, &sync.RWMutex{})
+	}
+}
 
-	go func() {
-		for {
-			if testCounter > 0 {
-				//serv.Close()
-			}
-		}
-	}()
+func init() {
+	serv = NewServer("127.0.0.1:0", nil, mutex)
+	err := serv.Start()
+	if err != nil {
+		panic(err)
+	}
+	binAddr = serv.Addr().String()
 }
 
 func TestRegisterCommand(t *testing.T) {
@@ -197,6 +207,90 @@ func TestServicesCommand(t *testing.T) {
 	fmt.Println(respServices)
 }
 
+func TestServicesCommandPagination(t *testing.T) {
+	var sr = ServicesRequest{
+		SortBy: SortByName,
+		Limit:  1,
+		Offset: 1,
+	}
+
+	srJSON, err := json.Marshal(sr)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	resp := tcpReq(t, Request{Cmd: Services, Req: string(srJSON)})
+	var respServices ServicesResponse
+	err = json.Unmarshal([]byte(resp.Resp), &respServices)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if respServices.Total != 2 {
+		t.Errorf("Total should be 2, instead of %d", respServices.Total)
+	}
+	if len(respServices.Services) != 1 {
+		t.Fatalf("Page should contain 1 service, instead of %d", len(respServices.Services))
+	}
+	if respServices.Services[0].Name != "websrever2" {
+		t.Errorf("Name should be websrever2, instead of %s", respServices.Services[0].Name)
+	}
+
+	srJSON, _ = json.Marshal(ServicesRequest{SortBy: "port"})
+	resp = tcpReq(t, Request{Cmd: Services, Req: string(srJSON)})
+	err = json.Unmarshal([]byte(resp.Resp), &respServices)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if respServices.Success != false || respServices.Error != ErrInvalidSortKey.Error() {
+		t.Errorf("Invalid sort key should fail, instead of %v %s", respServices.Success, respServices.Error)
+	}
+}
+
+func TestRegisterCheckFailures(t *testing.T) {
+	hcStorage := func(name string) (time.Duration, func() (bool, error)) {
+		return time.Hour, func() (bool, error) {
+			panic("misbehaving check")
+		}
+	}
+	s := newTestServer(t, withHealthcheckStorage(hcStorage))
+
+	var resp RegisterResponse
+	err := s.register(&RegisterRequest{
+		Name:    "failing",
+		Address: localhost,
+		Port:    1,
+		Checks:  []CheckDefinition{{Name: "tcp", Type: CheckTCP}},
+	}, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !resp.Success {
+		t.Fatalf("Failing checks shouldn't fail the registration, instead of %s", resp.Error)
+	}
+	if len(resp.CheckFailures) != 2 {
+		t.Fatalf("Both checks should be reported, instead of %v", resp.CheckFailures)
+	}
+	if result := resp.CheckFailures[DefaultCheckName]; result.Status != StatusCritical || result.Output != "check panicked: misbehaving check" {
+		t.Errorf("Panicking check should be critical, instead of %s %s", result.Status, result.Output)
+	}
+	if result := resp.CheckFailures["tcp"]; result.Status != StatusCritical || result.Output == "" {
+		t.Errorf("Refused check should be critical with output, instead of %s %s", result.Status, result.Output)
+	}
+
+	ss, err := s.storage.Service(&resp.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Status != StatusCritical {
+		t.Errorf("Service should be critical, instead of %s", ss.Status)
+	}
+}
+
 func tcpReq(t *testing.T, req Request) *Response {
 	rJSON, err := json.Marshal(req)
 	if err != nil {
@@ -223,3 +317,230 @@ func tcpReq(t *testing.T, req Request) *Response {
 
 	return &resp
 }
+
+func TestPipelinedRequests(t *testing.T) {
+	s := newTestServer(t)
+	if _, err := s.storage.Register("slow", localhost, 9014, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.faults.set([]Fault{{Commands: []Command{Service}, Service: "slow", Latency: 100 * time.Millisecond}}); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			s.serve(conn)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	slow := "slow"
+	slowJSON, _ := json.Marshal(ServiceRequest{Name: &slow})
+	servicesJSON, _ := json.Marshal(ServicesRequest{})
+	for _, req := range []Request{
+		{ID: "1", Cmd: Service, Req: string(slowJSON)},
+		{ID: "2", Cmd: Services, Req: string(servicesJSON)},
+	} {
+		rJSON, _ := json.Marshal(req)
+		if _, err := conn.Write(append(rJSON, delimiterByte)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader := bufio.NewReader(conn)
+	var ids []string
+	for i := 0; i < 2; i++ {
+		message, err := reader.ReadBytes(delimiterByte)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp Response
+		if err := json.Unmarshal(message, &resp); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, resp.ID)
+	}
+	if ids[0] != "2" || ids[1] != "1" {
+		t.Errorf("The fast request should be answered first, instead of %v", ids)
+	}
+}
+
+func TestInvalidRequests(t *testing.T) {
+	s := newTestServer(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			s.serve(conn)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// the invalid payload and header are answered, the connection goes on
+	for _, line := range []string{
+		`{"cmd":3,"id":"7","version":2,"payload":{"name":5}}`,
+		`{"cmd":"service","id":"8","version":2}`,
+		`{"cmd":2,"id":"9","version":2}`,
+	} {
+		if _, err := conn.Write(append([]byte(line), delimiterByte)); err != nil {
+			t.Fatal(err)
+		}
+		message, err := reader.ReadBytes(delimiterByte)
+		if err != nil {
+			t.Fatalf("%s should be answered, instead of %v", line, err)
+		}
+		var resp Response
+		if err := json.Unmarshal(message, &resp); err != nil {
+			t.Fatal(err)
+		}
+		var errorResp ErrorResponse
+		if err := json.Unmarshal(resp.Payload, &errorResp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.ID == "9" {
+			if resp.Code != 0 || !errorResp.Success {
+				t.Errorf("The valid request should succeed, instead of %d %s", resp.Code, errorResp.Error)
+			}
+			continue
+		}
+		if resp.Code != CodeBadRequest || errorResp.Success || !strings.HasPrefix(errorResp.Error, ErrInvalidRequest.Error()) {
+			t.Errorf("%s should be a bad request, instead of %s %d %+v", line, resp.ID, resp.Code, errorResp)
+		}
+	}
+
+	// a frame which isn't JSON closes the connection
+	if _, err := conn.Write([]byte("not json\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.ReadBytes(delimiterByte); err != io.EOF {
+		t.Errorf("The connection should be closed, instead of %v", err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	var server = NewServer("127.0.0.1:0", nil, &sync.RWMutex{})
+	select {
+	case <-server.Ready():
+		t.Fatal("Server shouldn't be ready before Start")
+	default:
+	}
+	if server.Addr() != nil {
+		t.Errorf("Addr should be nil before Start, instead of %v", server.Addr())
+	}
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	select {
+	case <-server.Ready():
+	default:
+		t.Fatal("Server should be ready after Start")
+	}
+	addr, ok := server.Addr().(*net.TCPAddr)
+	if !ok || addr.Port == 0 {
+		t.Fatalf("Addr should be the bound TCP address, instead of %v", server.Addr())
+	}
+	if err := server.Start(); err != errAlreadyListening {
+		t.Errorf("Error should be %v, instead of %v", errAlreadyListening, err)
+	}
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestConcurrentStart(t *testing.T) {
+	var server = NewServer("127.0.0.1:0", nil, &sync.RWMutex{})
+	defer server.Close()
+
+	var errs = make(chan error, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- server.Start()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var started int
+	for err := range errs {
+		switch err {
+		case nil:
+			started++
+		case errAlreadyListening:
+		default:
+			t.Error(err)
+		}
+	}
+	if started != 1 {
+		t.Errorf("The server should be started once, instead of %d", started)
+	}
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestReadyAfterFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// the port is in use, the ones waiting for Ready aren't blocked
+	var server = NewServer(ln.Addr().String(), nil, &sync.RWMutex{})
+	defer server.Close()
+	var listenErr = make(chan error, 1)
+	go func() {
+		listenErr <- server.Listen()
+	}()
+	select {
+	case <-server.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("Server should be ready after the failed Listen")
+	}
+	if server.Addr() != nil {
+		t.Errorf("Addr should be nil after the failed Listen, instead of %v", server.Addr())
+	}
+	if err := <-listenErr; err == nil {
+		t.Error("Listen should fail on the used port")
+	}
+
+	var closed = NewServer("127.0.0.1:0", nil, &sync.RWMutex{})
+	closed.Close()
+	select {
+	case <-closed.Ready():
+	default:
+		t.Error("Server should be ready after Close")
+	}
+}
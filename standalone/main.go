@@ -1,12 +1,93 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"os"
 	"sync"
 
 	"github.com/PumpkinSeed/catalog"
 )
 
+var (
+	certFile = flag.String("cert", "", "certificate file of the TLS listener")
+	keyFile  = flag.String("key", "", "private key file of the TLS listener")
+	caFile   = flag.String("ca", "", "CA file verifying the client certificates, enables mutual TLS")
+
+	masterToken = flag.String("acl-master-token", "", "admin token of the ACLs, enables the ACLs")
+
+	execChecks = flag.Bool("enable-exec-checks", false, "allow the clients to register exec checks, they run any command on the server")
+
+	unixSocket = flag.String("unix", "", "path of a unix domain socket served besides the TCP address, its mode is 0660")
+
+	grpcAddr = flag.String("grpc", "", "TCP address of the gRPC API, e.g. 127.0.0.1:7778")
+	dnsAddr  = flag.String("dns", "", "UDP and TCP address of the DNS interface, e.g. 127.0.0.1:8600")
+	dnsToken = flag.String("dns-token", "", "token of the DNS queries, the DNS answers the services readable by it when the ACLs are enabled")
+)
+
 func main() {
-	serv := catalog.NewServer("127.0.0.1:7777", nil, &sync.RWMutex{})
+	flag.Parse()
+
+	var options []catalog.ServerOption
+	if *certFile != "" || *keyFile != "" || *caFile != "" {
+		config, err := tlsConfig(*certFile, *keyFile, *caFile)
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, catalog.WithTLS(config))
+	}
+	if *masterToken != "" {
+		options = append(options, catalog.WithACL(*masterToken))
+	}
+	if *execChecks {
+		options = append(options, catalog.WithExecChecks())
+	}
+	if *unixSocket != "" {
+		options = append(options, catalog.WithUnixSocket(*unixSocket, 0660))
+	}
+	if *grpcAddr != "" {
+		options = append(options, catalog.WithGRPC(*grpcAddr))
+	}
+	if *dnsAddr != "" {
+		options = append(options, catalog.WithDNS(*dnsAddr))
+	}
+	if *dnsToken != "" {
+		options = append(options, catalog.WithDNSToken(*dnsToken))
+	}
+
+	serv := catalog.NewServer("127.0.0.1:7777", nil, &sync.RWMutex{}, options...)
 	panic(serv.Listen())
 }
+
+// tlsConfig loads the certificate of the listener, with caFile the
+// clients have to present a certificate signed by the CA
+func tlsConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both -cert and -key are required for TLS")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	var config = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caFile == "" {
+		return config, nil
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("no certificate found in -ca")
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
@@ -0,0 +1,315 @@
+package catalog
+
+import "time"
+
+// Status is the health status of a service or a check
+type Status string
+
+// Consul-style health statuses
+const (
+	StatusPassing  Status = "passing"
+	StatusWarning  Status = "warning"
+	StatusCritical Status = "critical"
+)
+
+// Modes of the HealthPolicy
+const (
+	// PolicyAll is passing when all of the checks passing
+	PolicyAll = "all"
+	// PolicyAny is passing when any of the checks passing
+	PolicyAny = "any"
+	// PolicyWeighted is passing when the weight of the passing checks reaches the threshold
+	PolicyWeighted = "weighted"
+)
+
+// defaultThreshold is the threshold of the weighted policy without Threshold
+const defaultThreshold = 0.5
+
+// DefaultCheckName is the name of the check set up by SetupHealthcheck
+// and the healthcheck storage
+const DefaultCheckName = "healthcheck"
+
+// HealthPolicy computes the status of the service from the status of its checks,
+// empty Mode means PolicyAll
+type HealthPolicy struct {
+	Mode string `json:"mode"`
+	// Threshold is the share of the passing weight needed by PolicyWeighted, default is 0.5
+	Threshold float64 `json:"threshold"`
+}
+
+// Validate checks the mode of the policy
+func (p HealthPolicy) Validate() error {
+	switch p.Mode {
+	case "", PolicyAll, PolicyAny, PolicyWeighted:
+	default:
+		return ErrUnknownHealthPolicy
+	}
+	if p.Threshold < 0 || p.Threshold > 1 {
+		return ErrUnknownHealthPolicy
+	}
+	return nil
+}
+
+// Check is a named healthcheck of a service
+type Check struct {
+	Name string
+	Func CheckFunc
+	// Interval of the check, zero means the interval of the service
+	Interval time.Duration
+	// Timeout of a run of the check, zero means the Interval
+	Timeout time.Duration
+	// Weight of the check in PolicyWeighted, zero means 1
+	Weight int
+
+	// SuccessBeforePassing is the number of consecutive successful runs
+	// needed to become passing, zero means 1
+	SuccessBeforePassing int
+	// FailuresBeforeWarning is the number of consecutive failed runs needed
+	// to become warning, zero means FailuresBeforeCritical
+	FailuresBeforeWarning int
+	// FailuresBeforeCritical is the number of consecutive failed runs
+	// needed to become critical, zero means 1
+	FailuresBeforeCritical int
+}
+
+func (c *Check) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return defaultCheckTimeout
+}
+
+func (c *Check) weight() int {
+	if c.Weight <= 0 {
+		return 1
+	}
+	return c.Weight
+}
+
+// damp returns the status of the check after the latest run, the status changes
+// only after the configured number of consecutive runs with the same outcome
+func (c *Check) damp(current Status, latest Status, successes int, failures int) Status {
+	// the first run of the check has nothing to damp
+	if current == "" {
+		return latest
+	}
+
+	beforePassing := atLeastOne(c.SuccessBeforePassing)
+	beforeCritical := atLeastOne(c.FailuresBeforeCritical)
+	beforeWarning := beforeCritical
+	if c.FailuresBeforeWarning > 0 {
+		beforeWarning = c.FailuresBeforeWarning
+	}
+
+	switch {
+	case latest == StatusPassing && successes >= beforePassing:
+		return StatusPassing
+	case latest == StatusCritical && failures >= beforeCritical:
+		return StatusCritical
+	case latest == StatusWarning && failures >= beforeWarning:
+		return StatusWarning
+	// a passing check goes through warning before critical
+	case latest == StatusCritical && failures >= beforeWarning && current == StatusPassing:
+		return StatusWarning
+	}
+	return current
+}
+
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// CheckResult is the latest result of a healthcheck
+type CheckResult struct {
+	Status      Status    `json:"status"`
+	Output      string    `json:"output"`
+	LastChecked time.Time `json:"last_checked"`
+	LastChange  time.Time `json:"last_change"`
+
+	// consecutive runs with the same outcome, used by the flap damping
+	ConsecutiveSuccesses int `json:"consecutive_successes"`
+	ConsecutiveFailures  int `json:"consecutive_failures"`
+}
+
+// statusOf maps the result of a healthcheck closure to a status,
+// an error of an alive service is a warning
+func statusOf(alive bool, err error) Status {
+	switch {
+	case alive && err == nil:
+		return StatusPassing
+	case alive:
+		return StatusWarning
+	}
+	return StatusCritical
+}
+
+// setResult records the result of the named check on the service and in its history,
+// the error is the output. The mutex of the storage should be locked.
+// It reports whether the status of the service changed.
+func (s *ServiceSpec) setResult(name string, latest Status, err error, now time.Time, latency time.Duration) bool {
+	var output string
+	if err != nil {
+		output = err.Error()
+	}
+	if s.history == nil {
+		s.history = newHistory(healthHistorySize)
+	}
+	s.history.add(HealthRecord{
+		Check:     name,
+		Timestamp: now,
+		Status:    latest,
+		Latency:   latency,
+		Output:    output,
+	})
+
+	if s.Checks == nil {
+		s.Checks = make(map[string]*CheckResult)
+	}
+	result, ok := s.Checks[name]
+	if !ok {
+		result = &CheckResult{}
+		s.Checks[name] = result
+	}
+
+	if latest == StatusPassing {
+		result.ConsecutiveSuccesses++
+		result.ConsecutiveFailures = 0
+	} else {
+		result.ConsecutiveFailures++
+		result.ConsecutiveSuccesses = 0
+	}
+
+	status := latest
+	if check, ok := s.healthchecks[name]; ok {
+		status = check.damp(result.Status, latest, result.ConsecutiveSuccesses, result.ConsecutiveFailures)
+	}
+
+	if result.Status != status {
+		result.LastChange = now
+	}
+	result.Status = status
+	result.Output = output
+	result.LastChecked = now
+
+	return s.updateStatus(now)
+}
+
+// updateStatus computes the status of the service from its checks with the health policy
+// and its dependencies, a service without checks and unhealthy dependencies has empty
+// status, an overridden one has the status of the override and one in maintenance
+// is critical. It reports whether the status changed.
+func (s *ServiceSpec) updateStatus(now time.Time) bool {
+	// own is the status of the service itself, from the override or the checks
+	var own Status
+	if s.Override != nil {
+		own = s.Override.Status
+	} else if len(s.Checks) > 0 {
+		switch s.HealthPolicy.Mode {
+		case PolicyAny:
+			own = s.anyStatus()
+		case PolicyWeighted:
+			own = s.weightedStatus()
+		default:
+			own = s.allStatus()
+		}
+	}
+
+	var status Status
+	if s.Maintenance != nil {
+		status = StatusCritical
+	} else if s.Override != nil {
+		status = own
+	} else {
+		status = worse(own, s.DependencyStatus)
+	}
+
+	changed := s.Status != status
+	s.Status = status
+	s.IsAlive = status == StatusPassing || status == StatusWarning
+	// only the own status starts the critical clock, a cascading outage is reported
+	// but not reaped, and the maintenance keeps the service until it ends
+	if own != StatusCritical || s.Maintenance != nil {
+		s.CriticalSince = time.Time{}
+	} else if s.CriticalSince.IsZero() {
+		s.CriticalSince = now
+	}
+	return changed
+}
+
+// worse returns the worse of the statuses, empty is the best
+func worse(a Status, b Status) Status {
+	var rank = map[Status]int{StatusPassing: 1, StatusWarning: 2, StatusCritical: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// expired reports whether the service stayed critical longer than its DeregisterCriticalAfter,
+// a service in maintenance never expires
+func (s *ServiceSpec) expired(now time.Time) bool {
+	return s.DeregisterCriticalAfter > 0 && s.Maintenance == nil && !s.CriticalSince.IsZero() &&
+		now.Sub(s.CriticalSince) >= s.DeregisterCriticalAfter
+}
+
+// allStatus is the worst status of the checks
+func (s *ServiceSpec) allStatus() Status {
+	status := StatusPassing
+	for _, result := range s.Checks {
+		if result.Status == StatusCritical {
+			return StatusCritical
+		}
+		if result.Status == StatusWarning {
+			status = StatusWarning
+		}
+	}
+	return status
+}
+
+// anyStatus is the best status of the checks
+func (s *ServiceSpec) anyStatus() Status {
+	status := StatusCritical
+	for _, result := range s.Checks {
+		if result.Status == StatusPassing {
+			return StatusPassing
+		}
+		if result.Status == StatusWarning {
+			status = StatusWarning
+		}
+	}
+	return status
+}
+
+// weightedStatus is passing when the passing share of the weights reaches the
+// threshold, critical when nothing passing and warning otherwise
+func (s *ServiceSpec) weightedStatus() Status {
+	var total, passing int
+	for name, result := range s.Checks {
+		weight := 1
+		if check, ok := s.healthchecks[name]; ok {
+			weight = check.weight()
+		}
+		total += weight
+		if result.Status == StatusPassing {
+			passing += weight
+		}
+	}
+
+	threshold := s.HealthPolicy.Threshold
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	switch {
+	case float64(passing) >= threshold*float64(total):
+		return StatusPassing
+	case passing > 0:
+		return StatusWarning
+	}
+	return StatusCritical
+}
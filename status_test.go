@@ -0,0 +1,274 @@
+package catalog
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStatusOf(t *testing.T) {
+	var cases = []struct {
+		alive  bool
+		err    error
+		status Status
+	}{
+		{true, nil, StatusPassing},
+		{true, errors.New("slow response"), StatusWarning},
+		{false, nil, StatusCritical},
+		{false, errors.New("connection refused"), StatusCritical},
+	}
+
+	for _, c := range cases {
+		status := statusOf(c.alive, c.err)
+		if status != c.status {
+			t.Errorf("Status should be %s, instead of %s", c.status, status)
+		}
+	}
+}
+
+func TestSetResult(t *testing.T) {
+	var service ServiceSpec
+	first := time.Now()
+	if !service.setResult(DefaultCheckName, StatusPassing, nil, first, 0) {
+		t.Error("First result should change the status")
+	}
+
+	second := first.Add(time.Second)
+	if service.setResult(DefaultCheckName, StatusPassing, nil, second, 0) {
+		t.Error("Same status shouldn't change the status")
+	}
+	result := service.Checks[DefaultCheckName]
+	if !result.LastChange.Equal(first) || !result.LastChecked.Equal(second) {
+		t.Errorf("LastChange should be %v and LastChecked %v, instead of %v and %v", first, second, result.LastChange, result.LastChecked)
+	}
+
+	third := second.Add(time.Second)
+	if !service.setResult(DefaultCheckName, StatusCritical, errors.New("connection refused"), third, 0) {
+		t.Error("Critical result should change the status")
+	}
+	if service.IsAlive || service.Status != StatusCritical || result.Output != "connection refused" {
+		t.Errorf("Service should be critical with output, instead of %v %s %s", service.IsAlive, service.Status, result.Output)
+	}
+}
+
+func TestStorageCheckOutput(t *testing.T) {
+	hcStorage := func(name string) (time.Duration, func() (bool, error)) {
+		return time.Hour, func() (bool, error) {
+			return false, errors.New("dial tcp: connection refused")
+		}
+	}
+	storage := NewStorage(hcStorage, 2000*time.Millisecond, &sync.RWMutex{})
+	defer storage.Close()
+
+	id, err := storage.Register("down", localhost, 9003, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := storage.Service(&id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Status != StatusCritical {
+		t.Errorf("Status should be critical, instead of %s", service.Status)
+	}
+	result, ok := service.Checks[DefaultCheckName]
+	if !ok || result.Output != "dial tcp: connection refused" {
+		t.Errorf("Check output should be recorded, instead of %+v", result)
+	}
+}
+
+func TestHealthPolicy(t *testing.T) {
+	var passing = &CheckResult{Status: StatusPassing}
+	var warning = &CheckResult{Status: StatusWarning}
+	var critical = &CheckResult{Status: StatusCritical}
+
+	var cases = []struct {
+		policy HealthPolicy
+		checks map[string]*CheckResult
+		status Status
+	}{
+		{HealthPolicy{}, map[string]*CheckResult{"tcp": passing, "http": warning}, StatusWarning},
+		{HealthPolicy{Mode: PolicyAll}, map[string]*CheckResult{"tcp": passing, "http": critical}, StatusCritical},
+		{HealthPolicy{Mode: PolicyAny}, map[string]*CheckResult{"tcp": passing, "http": critical}, StatusPassing},
+		{HealthPolicy{Mode: PolicyAny}, map[string]*CheckResult{"tcp": warning, "http": critical}, StatusWarning},
+		{HealthPolicy{Mode: PolicyWeighted}, map[string]*CheckResult{"tcp": critical, "http": passing}, StatusPassing},
+		{HealthPolicy{Mode: PolicyWeighted, Threshold: 0.75}, map[string]*CheckResult{"tcp": passing, "http": critical}, StatusWarning},
+		{HealthPolicy{Mode: PolicyWeighted, Threshold: 0.75}, map[string]*CheckResult{"tcp": passing, "disk": critical, "http": passing}, StatusPassing},
+		{HealthPolicy{Mode: PolicyWeighted}, map[string]*CheckResult{"tcp": critical, "http": warning}, StatusCritical},
+	}
+
+	for i, c := range cases {
+		service := ServiceSpec{
+			HealthPolicy: c.policy,
+			Checks:       c.checks,
+			healthchecks: map[string]*Check{"http": {Name: "http", Weight: 2}},
+		}
+		service.updateStatus(time.Now())
+		if service.Status != c.status {
+			t.Errorf("Case %d: status should be %s, instead of %s", i, c.status, service.Status)
+		}
+	}
+}
+
+func TestStorageNamedChecks(t *testing.T) {
+	storage := NewStorage(nil, time.Hour, &sync.RWMutex{})
+	defer storage.Close()
+
+	id, err := storage.Register("named", localhost, 9004, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.AddCheck(id, Check{Name: "tcp", Func: AdaptCheck(func() (bool, error) { return true, nil })})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.AddCheck(id, Check{Name: "disk", Func: AdaptCheck(func() (bool, error) { return false, nil })})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, _ := storage.Service(&id, nil)
+	if service.Status != StatusCritical || len(service.Checks) != 2 {
+		t.Errorf("Service should be critical with 2 checks, instead of %s with %d", service.Status, len(service.Checks))
+	}
+
+	err = storage.SetHealthPolicy(id, HealthPolicy{Mode: PolicyAny})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, _ = storage.Service(&id, nil)
+	if service.Status != StatusPassing {
+		t.Errorf("Service should be passing with any policy, instead of %s", service.Status)
+	}
+
+	err = storage.SetHealthPolicy(id, HealthPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.RemoveCheck(id, "disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, _ = storage.Service(&id, nil)
+	if service.Status != StatusPassing || len(service.Checks) != 1 {
+		t.Errorf("Service should be passing with 1 check, instead of %s with %d", service.Status, len(service.Checks))
+	}
+
+	if err := storage.RemoveCheck(id, "disk"); err != ErrUndefinedCheck {
+		t.Errorf("Error should be %v, instead of %v", ErrUndefinedCheck, err)
+	}
+}
+
+func TestFlapDamping(t *testing.T) {
+	var service = ServiceSpec{
+		healthchecks: map[string]*Check{
+			"http": {Name: "http", SuccessBeforePassing: 2, FailuresBeforeWarning: 2, FailuresBeforeCritical: 3},
+		},
+	}
+
+	var steps = []struct {
+		alive  bool
+		status Status
+	}{
+		{true, StatusPassing},
+		{false, StatusPassing},
+		{true, StatusPassing},
+		{false, StatusPassing},
+		{false, StatusWarning},
+		{false, StatusCritical},
+		{true, StatusCritical},
+		{false, StatusCritical},
+		{true, StatusCritical},
+		{true, StatusPassing},
+	}
+
+	now := time.Now()
+	for i, step := range steps {
+		service.setResult("http", statusOf(step.alive, nil), nil, now.Add(time.Duration(i)*time.Second), 0)
+		if service.Checks["http"].Status != step.status {
+			t.Errorf("Step %d: status should be %s, instead of %s", i, step.status, service.Checks["http"].Status)
+		}
+	}
+}
+
+func TestDeregisterCriticalAfter(t *testing.T) {
+	var alive int32 = 1
+	var reasons = make(chan string, 1)
+	storage := NewStorage(nil, time.Hour, &sync.RWMutex{})
+	defer storage.Close()
+	storage.SetHooks(Hooks{
+		OnDeregister: func(service ServiceSpec) {
+			reasons <- service.DeregisterReason
+		},
+	})
+
+	id, err := storage.Register("crashing", localhost, 9006, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.SetDeregisterCriticalAfter(id, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.AddCheck(id, Check{Name: "tcp", Interval: 10 * time.Millisecond, Func: AdaptCheck(func() (bool, error) {
+		return atomic.LoadInt32(&alive) == 1, nil
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := storage.Service(&id, nil); err != nil {
+		t.Fatal("Passing service shouldn't be deregistered")
+	}
+
+	atomic.StoreInt32(&alive, 0)
+	select {
+	case reason := <-reasons:
+		if reason != "critical for more than 50ms" {
+			t.Errorf("Reason should be recorded, instead of %s", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Critical service should be deregistered")
+	}
+	if _, err := storage.Service(&id, nil); err != ErrUndefinedService {
+		t.Errorf("Error should be %v, instead of %v", ErrUndefinedService, err)
+	}
+}
+
+func TestDeregisterCriticalAfterWithoutCheckResult(t *testing.T) {
+	storage := NewStorage(nil, time.Hour, &sync.RWMutex{})
+	defer storage.Close()
+
+	// the forced critical services have no check result triggering the reap
+	crashing, err := storage.Register("crashing", localhost, 9006, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovering, err := storage.Register("recovering", localhost, 9007, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []Identifier{crashing, recovering} {
+		if err := storage.SetDeregisterCriticalAfter(id, 20*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.SetHealth(id, StatusCritical, "down", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.ClearOverride(recovering); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if _, err := storage.Service(&crashing, nil); err != ErrUndefinedService {
+		t.Errorf("Critical service should be deregistered on time, instead of %v", err)
+	}
+	if _, err := storage.Service(&recovering, nil); err != nil {
+		t.Errorf("Recovered service shouldn't be deregistered, instead of %v", err)
+	}
+}
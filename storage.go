@@ -1,6 +1,9 @@
 package catalog
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"strconv"
 	"sync"
 	"time"
@@ -12,8 +15,23 @@ type Storage interface {
 	Service(id *Identifier, name *string) (*ServiceSpec, error)
 	Services() map[Identifier]*ServiceSpec
 	SetupHealthcheck(id Identifier, f func() (bool, error)) error
-	Healthcheck(healthcheckMutex *sync.RWMutex) error
+	SetupHealthcheckWithInterval(id Identifier, interval time.Duration, f func() (bool, error)) error
+	AddCheck(id Identifier, check Check) error
+	RemoveCheck(id Identifier, name string) error
+	SetHealthPolicy(id Identifier, policy HealthPolicy) error
+	SetDeregisterCriticalAfter(id Identifier, after time.Duration) error
+	SetHealth(id Identifier, status Status, reason string, duration time.Duration) error
+	ClearOverride(id Identifier) error
+	SetDependencies(id Identifier, dependencies []Dependency) error
+	EnableMaintenance(id Identifier, reason string) error
+	DisableMaintenance(id Identifier) error
+	DependencyGraph() DependencyGraph
+	HealthHistory(id *Identifier, name *string, check string) ([]HealthRecord, error)
+	Healthcheck(ctx context.Context) error
 	HealthcheckPeriod() time.Duration
+	SetHooks(hooks Hooks)
+	Watch(f func(event Event)) (stop func())
+	Close()
 }
 
 // ServiceSpec represent the specification of a service
@@ -25,11 +43,65 @@ type ServiceSpec struct {
 	Address string     `json:"address"`
 	Tags    []string   `json:"tags"`
 
-	Healthcheck     bool                 `json:"healthcheck"`
-	HealthcheckFunc func() (bool, error) `json:"-"`
-	IsAlive         bool                 `json:"is_alive"`
+	RegisteredAt time.Time `json:"registered_at"`
+
+	Healthcheck         bool          `json:"healthcheck"`
+	HealthcheckInterval time.Duration `json:"healthcheck_interval"`
+	HealthPolicy        HealthPolicy  `json:"health_policy"`
+	IsAlive             bool          `json:"is_alive"`
+	// Status is empty when the service has no healthcheck
+	Status Status                  `json:"status"`
+	Checks map[string]*CheckResult `json:"checks"`
+
+	// DeregisterCriticalAfter removes the service after its own checks or override were
+	// critical for this long, zero means never. The reason of the removal is the DeregisterReason.
+	DeregisterCriticalAfter time.Duration `json:"deregister_critical_after"`
+	CriticalSince           time.Time     `json:"critical_since"`
+	DeregisterReason        string        `json:"deregister_reason"`
+
+	// Override forces the Status regardless of the checks, see SetHealth
+	Override *HealthOverride `json:"override,omitempty"`
+	// Maintenance makes the service critical and hides it from the healthy services
+	Maintenance *Maintenance `json:"maintenance,omitempty"`
+
+	// Dependencies on other services, the DependencyStatus is critical when a required
+	// dependency has no healthy instances and warning when an optional one
+	Dependencies     []Dependency `json:"dependencies"`
+	DependencyStatus Status       `json:"dependency_status"`
 
 	Additional interface{}
+
+	healthchecks map[string]*Check
+	history      *history
+}
+
+// copy returns a copy of the service which doesn't share the tags and the check results
+func (s *ServiceSpec) copy() ServiceSpec {
+	c := *s
+	if s.Tags != nil {
+		c.Tags = append([]string(nil), s.Tags...)
+	}
+	if s.Checks != nil {
+		c.Checks = make(map[string]*CheckResult, len(s.Checks))
+		for name, result := range s.Checks {
+			r := *result
+			c.Checks[name] = &r
+		}
+	}
+	if s.Override != nil {
+		o := *s.Override
+		c.Override = &o
+	}
+	if s.Maintenance != nil {
+		m := *s.Maintenance
+		c.Maintenance = &m
+	}
+	if s.Dependencies != nil {
+		c.Dependencies = append([]Dependency(nil), s.Dependencies...)
+	}
+	c.healthchecks = nil
+	c.history = nil
+	return c
 }
 
 type storage struct {
@@ -37,22 +109,47 @@ type storage struct {
 	services           map[Identifier]*ServiceSpec
 	healthcheckStorage func(name string) (time.Duration, func() (bool, error))
 	healthcheckPeriod  time.Duration
+	dispatcher         *dispatcher
+	scheduler          *scheduler
+	workers            chan struct{}
+	// ctx cancels the running checks on Close
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func NewStorage(healthcheckStorage func(name string) (time.Duration, func() (bool, error)), healthcheckPeriod time.Duration, mutex *sync.RWMutex) Storage {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &storage{
 		services:           make(map[Identifier]*ServiceSpec),
 		healthcheckStorage: healthcheckStorage,
 		healthcheckPeriod:  healthcheckPeriod,
 		mutex:              mutex,
+		dispatcher:         &dispatcher{},
+		scheduler:          newScheduler(),
+		workers:            make(chan struct{}, healthcheckWorkers),
+		ctx:                ctx,
+		cancel:             cancel,
 	}
 }
 
 func (s *storage) Register(name string, host string, port int, tags []string, additional interface{}) (Identifier, error) {
 	var hcFunc func() (bool, error)
+	var interval time.Duration
 	if s.healthcheckStorage != nil {
-		_, hcFunc = s.healthcheckStorage(name)
+		interval, hcFunc = s.healthcheckStorage(name)
+	}
+	if interval <= 0 {
+		interval = s.healthcheckPeriod
 	}
+	var check *Check
+	var status Status
+	var latency time.Duration
+	var hcErr error
+	if hcFunc != nil {
+		check = &Check{Name: DefaultCheckName, Func: AdaptCheck(hcFunc), Interval: interval}
+		status, latency, hcErr = runCheck(s.ctx, check)
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -65,10 +162,18 @@ func (s *storage) Register(name string, host string, port int, tags []string, ad
 		Address:    host + ":" + strconv.Itoa(port),
 		Tags:       tags,
 		Additional: additional,
+
+		RegisteredAt: time.Now(),
+
+		HealthcheckInterval: interval,
 	}
 	s.services[id] = &service
 
-	s.SetupHealthcheck(id, hcFunc)
+	if check != nil {
+		s.addCheck(&service, check, status, latency, hcErr)
+	}
+	s.dispatcher.emit(EventRegister, &service)
+	s.propagate(time.Now())
 	return id, nil
 }
 
@@ -77,16 +182,62 @@ func (s *storage) Deregister(id *Identifier, name *string) error {
 	defer s.mutex.Unlock()
 
 	// ID first manner
+	var ss *ServiceSpec
 	if id != nil {
-		delete(s.services, *id)
-		return nil
+		ss = s.services[*id]
 	} else if name != nil {
-		ss := s.findByName(*name)
-		delete(s.services, ss.ID)
-		return nil
+		ss = s.findByName(*name)
+	} else {
+		return ErrServiceRequestInvalid
 	}
 
-	return ErrServiceRequestInvalid
+	if ss == nil {
+		if id != nil {
+			return nil
+		}
+		return ErrUndefinedService
+	}
+	s.deregister(ss, "deregistered")
+	return nil
+}
+
+// deregister removes the service for the reason, the mutex should be locked
+func (s *storage) deregister(service *ServiceSpec, reason string) {
+	service.DeregisterReason = reason
+	delete(s.services, service.ID)
+	s.scheduler.unscheduleService(service.ID)
+	s.dispatcher.emit(EventDeregister, service)
+	s.propagate(time.Now())
+}
+
+// reapKey schedules the deregistration of a critical service, the checks
+// can't have this name
+const reapKey = "\x00reap"
+
+// reap deregisters the service if it stayed critical for too long, otherwise the
+// deregistration is scheduled at the end of the period, the mutex should be locked
+func (s *storage) reap(service *ServiceSpec, now time.Time) {
+	key := checkKey{id: service.ID, name: reapKey}
+	if service.DeregisterCriticalAfter <= 0 || service.Maintenance != nil || service.CriticalSince.IsZero() {
+		s.scheduler.unschedule(key)
+		return
+	}
+	if !service.expired(now) {
+		id := service.ID
+		s.scheduler.after(key, service.CriticalSince.Add(service.DeregisterCriticalAfter).Sub(now), func() {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			// the service recovered or deregistered meanwhile
+			if service, ok := s.services[id]; ok {
+				s.reap(service, time.Now())
+			}
+		})
+		return
+	}
+
+	reason := fmt.Sprintf("critical for more than %v", service.DeregisterCriticalAfter)
+	log.Printf("catalog: deregister %s (%d): %s", service.Name, service.ID, reason)
+	s.deregister(service, reason)
 }
 
 func (s *storage) Service(id *Identifier, name *string) (*ServiceSpec, error) {
@@ -94,63 +245,290 @@ func (s *storage) Service(id *Identifier, name *string) (*ServiceSpec, error) {
 	var ok bool
 
 	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
 	// ID first manner
 	if id != nil {
 		service, ok = s.services[*id]
 	} else if name != nil {
-		ss := s.findByName(*name)
-		service, ok = s.services[ss.ID]
+		service = s.findByName(*name)
+		ok = service != nil
 	} else {
 		return service, ErrServiceRequestInvalid
 	}
-	s.mutex.RUnlock()
 	if !ok {
 		return &ServiceSpec{}, ErrUndefinedService
 	}
-	return service, nil
+
+	// copy, the healthchecks modify the stored one
+	c := service.copy()
+	return &c, nil
 }
 
+// Services returns a snapshot of the services
 func (s *storage) Services() map[Identifier]*ServiceSpec {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	return s.services
+
+	var services = make(map[Identifier]*ServiceSpec, len(s.services))
+	for id, service := range s.services {
+		c := service.copy()
+		services[id] = &c
+	}
+	return services
 }
 
+// SetupHealthcheck replaces the default check of the service
 func (s *storage) SetupHealthcheck(id Identifier, f func() (bool, error)) error {
+	return s.SetupHealthcheckWithInterval(id, 0, f)
+}
+
+// SetupHealthcheckWithInterval replaces the default check of the service with its interval,
+// zero interval means the interval of the service. The check is set up even if its
+// first run fails, the error of the run is returned.
+func (s *storage) SetupHealthcheckWithInterval(id Identifier, interval time.Duration, f func() (bool, error)) error {
 	// Check service before setup healthcheck
 	if f == nil {
 		return nil
 	}
-	alive, err := f()
-	if err != nil {
-		return err
+
+	return s.AddCheck(id, Check{Name: DefaultCheckName, Func: AdaptCheck(f), Interval: interval})
+}
+
+// AddCheck adds the named check to the service or replaces the check with the same name.
+// The check is set up even if its first run fails, the error of the run is returned.
+func (s *storage) AddCheck(id Identifier, check Check) error {
+	if check.Name == "" || check.Name == reapKey || check.Func == nil {
+		return ErrInvalidCheck
 	}
 
+	// the interval is the default timeout of the first run
+	s.mutex.RLock()
 	service, ok := s.services[id]
+	if ok && check.Interval <= 0 {
+		check.Interval = service.HealthcheckInterval
+	}
+	s.mutex.RUnlock()
+	if !ok {
+		return ErrUndefinedService
+	}
+	status, latency, hcErr := runCheck(s.ctx, &check)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	service, ok = s.services[id]
 	if !ok {
 		return ErrUndefinedService
 	}
-	if alive {
-		service.IsAlive = true
+	changed := s.addCheck(service, &check, status, latency, hcErr)
+
+	now := time.Now()
+	s.dispatcher.emit(EventUpdate, service)
+	if changed {
+		s.dispatcher.emit(EventHealthChange, service)
+		s.propagate(now)
 	}
+	s.reap(service, now)
+	// the output of a passing check isn't a failure
+	if status == StatusPassing {
+		return nil
+	}
+	return hcErr
+}
 
+// addCheck stores the check of the service with the result of its first run and
+// schedules it, the mutex should be locked. It reports whether the status changed.
+func (s *storage) addCheck(service *ServiceSpec, check *Check, status Status, latency time.Duration, err error) bool {
+	if check.Interval <= 0 {
+		check.Interval = service.HealthcheckInterval
+	}
+	if check.Interval <= 0 {
+		check.Interval = s.healthcheckPeriod
+	}
+	if service.healthchecks == nil {
+		service.healthchecks = make(map[string]*Check)
+	}
+	service.healthchecks[check.Name] = check
 	service.Healthcheck = true
-	service.HealthcheckFunc = f
+	// a replaced check starts without history
+	delete(service.Checks, check.Name)
+	changed := service.setResult(check.Name, status, err, time.Now(), latency)
+
+	if check.Interval > 0 {
+		id, name := service.ID, check.Name
+		s.scheduler.schedule(checkKey{id: id, name: name}, check.Interval, func() {
+			s.check(id, name)
+		})
+	}
 
-	s.services[id] = service
+	return changed
+}
+
+// RemoveCheck removes the named check of the service
+func (s *storage) RemoveCheck(id Identifier, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
+	service, ok := s.services[id]
+	if !ok {
+		return ErrUndefinedService
+	}
+	if _, ok := service.healthchecks[name]; !ok {
+		return ErrUndefinedCheck
+	}
+
+	s.scheduler.unschedule(checkKey{id: id, name: name})
+	delete(service.healthchecks, name)
+	delete(service.Checks, name)
+	service.Healthcheck = len(service.healthchecks) > 0
+	now := time.Now()
+	changed := service.updateStatus(now)
+
+	s.dispatcher.emit(EventUpdate, service)
+	if changed {
+		s.dispatcher.emit(EventHealthChange, service)
+		s.propagate(now)
+	}
+	s.reap(service, now)
+	return nil
+}
+
+// SetDeregisterCriticalAfter sets the duration after a critical service deregistered,
+// zero means never
+func (s *storage) SetDeregisterCriticalAfter(id Identifier, after time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	service, ok := s.services[id]
+	if !ok {
+		return ErrUndefinedService
+	}
+	service.DeregisterCriticalAfter = after
+
+	s.dispatcher.emit(EventUpdate, service)
+	s.reap(service, time.Now())
+	return nil
+}
+
+// SetHealthPolicy replaces the policy computing the status of the service from its checks
+func (s *storage) SetHealthPolicy(id Identifier, policy HealthPolicy) error {
+	err := policy.Validate()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	service, ok := s.services[id]
+	if !ok {
+		return ErrUndefinedService
+	}
+	service.HealthPolicy = policy
+	now := time.Now()
+	changed := service.updateStatus(now)
+
+	s.dispatcher.emit(EventUpdate, service)
+	if changed {
+		s.dispatcher.emit(EventHealthChange, service)
+		s.propagate(now)
+	}
+	s.reap(service, now)
 	return nil
 }
 
-func (s *storage) Healthcheck(healthcheckMutex *sync.RWMutex) error {
-	return healthcheck(s.services, healthcheckMutex)
+// HealthHistory returns the latest results of the checks of the service from
+// the oldest to the latest, empty check means all of the checks
+func (s *storage) HealthHistory(id *Identifier, name *string, check string) ([]HealthRecord, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var service *ServiceSpec
+	if id != nil {
+		service = s.services[*id]
+	} else if name != nil {
+		service = s.findByName(*name)
+	} else {
+		return nil, ErrServiceRequestInvalid
+	}
+	if service == nil {
+		return nil, ErrUndefinedService
+	}
+	if service.history == nil {
+		return []HealthRecord{}, nil
+	}
+
+	return service.history.list(check), nil
+}
+
+// check runs the scheduled check of the service like a job of the healthcheck rounds,
+// on one of the workers shared by the scheduled checks
+func (s *storage) check(id Identifier, name string) {
+	s.mutex.RLock()
+	var job healthcheckJob
+	if service, ok := s.services[id]; ok {
+		job = healthcheckJob{id: id, name: service.Name, service: service, check: service.healthchecks[name]}
+	}
+	s.mutex.RUnlock()
+	if job.check == nil {
+		return
+	}
+
+	s.workers <- struct{}{}
+	defer func() { <-s.workers }()
+	job.run(s.ctx, s.services, s.mutex, s.onResult)
+}
+
+// onResult publishes the result of a check, the mutex should be locked
+func (s *storage) onResult(service *ServiceSpec, changed bool) {
+	now := time.Now()
+	if changed {
+		s.dispatcher.emit(EventHealthChange, service)
+		s.propagate(now)
+	}
+	s.reap(service, now)
+}
+
+// Healthcheck runs the healthchecks of all of the services once
+func (s *storage) Healthcheck(ctx context.Context) error {
+	return healthcheck(ctx, s.services, s.mutex, healthcheckWorkers, s.onResult)
 }
 
 func (s *storage) HealthcheckPeriod() time.Duration {
 	return s.healthcheckPeriod
 }
 
+// SetHooks replaces the in-process callbacks of the storage
+func (s *storage) SetHooks(hooks Hooks) {
+	s.dispatcher.setHooks(hooks)
+}
+
+// Watch calls f with the changes of the storage from now on until stop is called,
+// after the hooks and on the same goroutine, so f shouldn't block
+func (s *storage) Watch(f func(event Event)) (stop func()) {
+	return s.dispatcher.watch(f)
+}
+
+// Close stops the scheduled healthchecks and cancels the running ones
+func (s *storage) Close() {
+	s.scheduler.stop()
+	s.cancel()
+}
+
+// updateStatus recomputes the status of the service after a change of its state and
+// emits the events of the change, the mutex should be locked
+func (s *storage) updateStatus(service *ServiceSpec, now time.Time) {
+	changed := service.updateStatus(now)
+
+	s.dispatcher.emit(EventUpdate, service)
+	if changed {
+		s.dispatcher.emit(EventHealthChange, service)
+		s.propagate(now)
+	}
+	s.reap(service, now)
+}
+
 func (s *storage) findByName(name string) *ServiceSpec {
 	for _, service := range s.services {
 		if service.Name == name {
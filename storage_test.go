@@ -1,6 +1,7 @@
 package catalog
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -49,7 +50,7 @@ func TestStorage(t *testing.T) {
 		t.Error(err)
 	}
 
-	err = storage.Healthcheck(mutex)
+	err = storage.Healthcheck(context.Background())
 	if err != nil {
 		t.Error(err)
 	}
@@ -69,3 +70,47 @@ func TestStorage(t *testing.T) {
 	}
 
 }
+
+func TestStorageHooks(t *testing.T) {
+	var events = make(chan string, 10)
+	storage := NewStorage(nil, 2000*time.Millisecond, &sync.RWMutex{})
+	storage.SetHooks(Hooks{
+		OnRegister: func(service ServiceSpec) {
+			events <- "register " + service.Name
+		},
+		OnUpdate: func(service ServiceSpec) {
+			events <- "update " + service.Name
+			panic("hook panic shouldn't stop the dispatcher")
+		},
+		OnHealthChange: func(service ServiceSpec) {
+			events <- fmt.Sprintf("health %s %v", service.Name, service.IsAlive)
+		},
+		OnDeregister: func(service ServiceSpec) {
+			events <- "deregister " + service.Name
+		},
+	})
+
+	id, err := storage.Register("hooked", "localhost", 8083, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.SetupHealthcheck(id, func() (bool, error) { return true, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Deregister(&id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"register hooked", "update hooked", "health hooked true", "deregister hooked"} {
+		select {
+		case e := <-events:
+			if e != expected {
+				t.Errorf("Event should be %s, instead of %s", expected, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Event %s didn't arrive", expected)
+		}
+	}
+}